@@ -0,0 +1,930 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce-backend/money"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultJWTSecret          = "default-secret-key"
+	minProductionSecretLength = 32
+	redacted                  = "[REDACTED]"
+)
+
+// Config holds every runtime setting of the server. It is loaded once at
+// startup and passed to the components that need it.
+type Config struct {
+	Environment string          `yaml:"environment"`
+	Server      ServerConfig    `yaml:"server"`
+	Database    DatabaseConfig  `yaml:"database"`
+	JWT         JWTConfig       `yaml:"jwt"`
+	Timeouts    TimeoutConfig   `yaml:"timeouts"`
+	Log         LogConfig       `yaml:"log"`
+	RateLimit   RateLimitConfig `yaml:"rate_limit"`
+	Lockout     LockoutConfig   `yaml:"lockout"`
+	Mail        MailConfig      `yaml:"mail"`
+	Account     AccountConfig   `yaml:"account"`
+	Password    PasswordConfig  `yaml:"password"`
+	TwoFactor   TwoFactorConfig `yaml:"two_factor"`
+	OIDC        OIDCConfig      `yaml:"oidc"`
+	CORS        CORSConfig      `yaml:"cors"`
+	Security    SecurityConfig  `yaml:"security"`
+	Session     SessionConfig   `yaml:"session"`
+	Tax         TaxConfig       `yaml:"tax"`
+	Currency    CurrencyConfig  `yaml:"currency"`
+	Shipping    ShippingConfig  `yaml:"shipping"`
+}
+
+type ServerConfig struct {
+	Port              string        `yaml:"port"`
+	PublicURL         string        `yaml:"public_url"` // base URL for links in emails
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+	// TrustedProxies are the addresses or CIDR ranges of the reverse
+	// proxies in front of the server. Client IPs are only taken from
+	// X-Forwarded-For when a request comes from one of them; with none the
+	// header is ignored.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type DatabaseConfig struct {
+	URI  string `yaml:"uri"`
+	Name string `yaml:"name"`
+}
+
+// JWTConfig controls access tokens. With Keys configured tokens are signed
+// with the newest active key (RS256 or EdDSA, by key type); otherwise they
+// are signed with Secret using HS256.
+type JWTConfig struct {
+	Secret   string         `yaml:"secret"`
+	Expiry   time.Duration  `yaml:"expiry"`
+	Issuer   string         `yaml:"issuer"`
+	Audience string         `yaml:"audience"`
+	Keys     []JWTKeyConfig `yaml:"keys"`
+}
+
+// JWTKeyConfig is a signing key. A key signs new tokens from ActiveFrom
+// until the next key becomes active, and verifies them until they expire.
+// Keys are published in the JWKS before they become active.
+type JWTKeyConfig struct {
+	ID         string    `yaml:"id"`
+	File       string    `yaml:"file"`
+	ActiveFrom time.Time `yaml:"active_from"`
+}
+
+// TimeoutConfig sets the request deadline for each route group.
+type TimeoutConfig struct {
+	Auth     time.Duration `yaml:"auth"`
+	Products time.Duration `yaml:"products"`
+	Cart     time.Duration `yaml:"cart"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// RateLimitConfig sets the token buckets applied per route group. Store is
+// "memory" (per instance) or "mongo" (shared by all instances).
+type RateLimitConfig struct {
+	Enabled bool        `yaml:"enabled"`
+	Store   string      `yaml:"store"`
+	AuthIP  LimitConfig `yaml:"auth_ip"`
+	// AuthAccount limits attempts against a single email address.
+	AuthAccount LimitConfig `yaml:"auth_account"`
+	Writes      LimitConfig `yaml:"writes"`
+}
+
+// LimitConfig allows Requests per Period with bursts of up to Burst
+// requests (defaulting to Requests).
+type LimitConfig struct {
+	Requests int           `yaml:"requests"`
+	Period   time.Duration `yaml:"period"`
+	Burst    int           `yaml:"burst"`
+}
+
+// LockoutConfig controls temporary account lockout after repeated failed
+// logins. Each consecutive lockout doubles the duration, up to MaxDuration.
+type LockoutConfig struct {
+	Threshold    int           `yaml:"threshold"`
+	BaseDuration time.Duration `yaml:"base_duration"`
+	MaxDuration  time.Duration `yaml:"max_duration"`
+}
+
+// MailConfig selects how email is delivered. Driver is "smtp", "file"
+// (writes .eml files to Dir) or "log".
+type MailConfig struct {
+	Driver string     `yaml:"driver"`
+	From   string     `yaml:"from"`
+	Dir    string     `yaml:"dir"`
+	SMTP   SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// AccountConfig sets how long emailed account tokens stay valid.
+type AccountConfig struct {
+	VerificationTTL  time.Duration `yaml:"verification_ttl"`
+	PasswordResetTTL time.Duration `yaml:"password_reset_ttl"`
+}
+
+// PasswordConfig sets the password policy and how passwords are hashed.
+// Algorithm is "bcrypt" or "argon2id"; stored hashes made with another
+// algorithm or weaker parameters are upgraded on the next successful login.
+type PasswordConfig struct {
+	MinLength        int          `yaml:"min_length"`
+	MaxLength        int          `yaml:"max_length"`
+	BreachedListFile string       `yaml:"breached_list_file"`
+	Algorithm        string       `yaml:"algorithm"`
+	BcryptCost       int          `yaml:"bcrypt_cost"`
+	Argon2           Argon2Config `yaml:"argon2"`
+}
+
+type Argon2Config struct {
+	Time      uint32 `yaml:"time"`
+	MemoryKiB uint32 `yaml:"memory_kib"`
+	Threads   uint8  `yaml:"threads"`
+}
+
+// TwoFactorConfig controls TOTP two-factor authentication. Users whose
+// role is listed in RequiredRoles can't use role-restricted endpoints until
+// they have enrolled.
+type TwoFactorConfig struct {
+	Issuer        string        `yaml:"issuer"`
+	ChallengeTTL  time.Duration `yaml:"challenge_ttl"`
+	RequiredRoles []string      `yaml:"required_roles"`
+}
+
+// OIDCConfig lists the OpenID Connect providers users can sign in with.
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig `yaml:"providers"`
+}
+
+// OIDCProviderConfig configures one provider. Name appears in the login
+// and callback URLs; the callback URL registered with the provider is
+// {server.public_url}/api/auth/oidc/{name}/callback.
+type OIDCProviderConfig struct {
+	Name         string   `yaml:"name"`
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+// CORSConfig holds the cross-origin policies. Public applies to reads of
+// the catalog, documentation and health endpoints; Private to everything
+// else (auth, account, cart, admin and catalog writes).
+type CORSConfig struct {
+	Public  CORSPolicy `yaml:"public"`
+	Private CORSPolicy `yaml:"private"`
+}
+
+// CORSPolicy lists what cross-origin callers may do. An allowed origin is
+// either an exact origin such as https://shop.example.com, a pattern with a
+// wildcard leftmost host label such as https://*.example.com, or "*" for
+// any origin (not allowed together with credentials).
+type CORSPolicy struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins"`
+	AllowedMethods   []string      `yaml:"allowed_methods"`
+	AllowedHeaders   []string      `yaml:"allowed_headers"`
+	ExposedHeaders   []string      `yaml:"exposed_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// SecurityConfig sets the security headers sent with every response and
+// the double-submit CSRF token required from cookie-authenticated browsers.
+type SecurityConfig struct {
+	ContentSecurityPolicy string        `yaml:"content_security_policy"`
+	HSTSMaxAge            time.Duration `yaml:"hsts_max_age"` // only sent when server.public_url is https; 0 disables
+	ReferrerPolicy        string        `yaml:"referrer_policy"`
+	CSRFCookieName        string        `yaml:"csrf_cookie_name"`
+	CSRFHeaderName        string        `yaml:"csrf_header_name"`
+}
+
+// SessionConfig controls the cookie set by logins with mode "cookie". The
+// cookie holds the access token, is HttpOnly, lives as long as the token
+// and is marked Secure when server.public_url is https.
+type SessionConfig struct {
+	CookieName string `yaml:"cookie_name"`
+	SameSite   string `yaml:"same_site"` // lax or strict
+}
+
+// TaxConfig sets how sales tax is charged. Mode is "exclusive" (tax is
+// added to product prices) or "inclusive" (product prices already contain
+// tax, which is broken out). Carts are taxed for the default country and
+// region until the customer gives an address.
+type TaxConfig struct {
+	Mode           string          `yaml:"mode"`
+	DefaultClass   string          `yaml:"default_class"` // for products without a tax class
+	DefaultCountry string          `yaml:"default_country"`
+	DefaultRegion  string          `yaml:"default_region"`
+	Rates          []TaxRateConfig `yaml:"rates"`
+}
+
+// TaxRateConfig is one row of the tax table. A rate applies to products of
+// its class shipped to its country, and to its region when one is set.
+// Every matching rate is charged, so a federal rate without a region and a
+// provincial rate with one add up.
+type TaxRateConfig struct {
+	Name    string  `yaml:"name"`    // shown on the tax line, e.g. "VAT"
+	Country string  `yaml:"country"` // ISO 3166-1 alpha-2
+	Region  string  `yaml:"region"`
+	Class   string  `yaml:"class"`
+	Percent float64 `yaml:"percent"`
+}
+
+// CurrencyConfig sets the base currency. Every product has a price in it;
+// exchange rates are relative to it.
+type CurrencyConfig struct {
+	Base string `yaml:"base"` // ISO 4217
+}
+
+// ShippingConfig sets the shipping methods offered in each zone. Weight
+// rates charge for the larger of a parcel's actual weight and its
+// dimensional weight, its volume in cm³ divided by VolumetricDivisor.
+type ShippingConfig struct {
+	VolumetricDivisor int64                `yaml:"volumetric_divisor"` // 0 to charge by actual weight only
+	Zones             []ShippingZoneConfig `yaml:"zones"`
+}
+
+// ShippingZoneConfig groups countries that share shipping methods. A zone
+// without countries covers every country not listed in another zone.
+type ShippingZoneConfig struct {
+	Name      string                 `yaml:"name"`
+	Countries []string               `yaml:"countries"` // ISO 3166-1 alpha-2
+	Methods   []ShippingMethodConfig `yaml:"methods"`
+}
+
+// ShippingMethodConfig is one way of shipping to a zone. Its amounts are
+// in the base currency, and under Currencies for carts priced in other
+// currencies; the method is not offered in currencies it lists no amounts
+// for.
+type ShippingMethodConfig struct {
+	Code                  string `yaml:"code"` // chosen at checkout, e.g. "standard"
+	Name                  string `yaml:"name"`
+	Type                  string `yaml:"type"` // flat or weight
+	ShippingAmountsConfig `yaml:",inline"`
+	Currencies            map[string]ShippingAmountsConfig `yaml:"currencies"` // keyed by ISO 4217 code
+	MaxWeight             int64                            `yaml:"max_weight"` // grams; 0 for no limit
+}
+
+// ShippingAmountsConfig is what a shipping method costs, in minor units of
+// one currency. Flat methods cost Price; weight methods cost Price plus
+// PerKg for every started kilogram. Orders whose subtotal after discounts
+// reaches FreeOver ship for free.
+type ShippingAmountsConfig struct {
+	Price    int64 `yaml:"price"`
+	PerKg    int64 `yaml:"per_kg"`
+	FreeOver int64 `yaml:"free_over"` // 0 to always charge
+}
+
+func (a ShippingAmountsConfig) valid() bool {
+	return a.Price >= 0 && a.PerKg >= 0 && a.FreeOver >= 0
+}
+
+// Default returns the configuration used for local development.
+func Default() *Config {
+	return &Config{
+		Environment: "development",
+		Server: ServerConfig{
+			Port:              "8080",
+			PublicURL:         "http://localhost:8080",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		Database: DatabaseConfig{
+			URI:  "mongodb://localhost:27017",
+			Name: "ecommerce_db",
+		},
+		JWT: JWTConfig{
+			Secret:   defaultJWTSecret,
+			Expiry:   7 * 24 * time.Hour,
+			Issuer:   "gommerce",
+			Audience: "gommerce-api",
+		},
+		Timeouts: TimeoutConfig{
+			Auth:     10 * time.Second,
+			Products: 5 * time.Second,
+			Cart:     5 * time.Second,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:     true,
+			Store:       "memory",
+			AuthIP:      LimitConfig{Requests: 20, Period: time.Minute},
+			AuthAccount: LimitConfig{Requests: 5, Period: time.Minute},
+			Writes:      LimitConfig{Requests: 60, Period: time.Minute},
+		},
+		Lockout: LockoutConfig{
+			Threshold:    5,
+			BaseDuration: time.Minute,
+			MaxDuration:  time.Hour,
+		},
+		Mail: MailConfig{
+			Driver: "log",
+			From:   "Gommerce <no-reply@localhost>",
+			Dir:    "tmp/mail",
+			SMTP:   SMTPConfig{Port: 587},
+		},
+		Account: AccountConfig{
+			VerificationTTL:  48 * time.Hour,
+			PasswordResetTTL: time.Hour,
+		},
+		Password: PasswordConfig{
+			MinLength:  8,
+			MaxLength:  128,
+			Algorithm:  "bcrypt",
+			BcryptCost: 12,
+			Argon2:     Argon2Config{Time: 3, MemoryKiB: 64 * 1024, Threads: 2},
+		},
+		TwoFactor: TwoFactorConfig{
+			Issuer:        "Gommerce",
+			ChallengeTTL:  5 * time.Minute,
+			RequiredRoles: []string{"staff", "admin"},
+		},
+		Security: SecurityConfig{
+			ContentSecurityPolicy: "default-src 'self'; img-src 'self' data: https:; style-src 'self' 'unsafe-inline'; " +
+				"script-src 'self'; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'",
+			HSTSMaxAge:     180 * 24 * time.Hour,
+			ReferrerPolicy: "strict-origin-when-cross-origin",
+			CSRFCookieName: "gommerce_csrf",
+			CSRFHeaderName: "X-CSRF-Token",
+		},
+		Session: SessionConfig{
+			CookieName: "gommerce_session",
+			SameSite:   "lax",
+		},
+		Tax: TaxConfig{
+			Mode:         "exclusive",
+			DefaultClass: "standard",
+		},
+		Currency: CurrencyConfig{Base: "USD"},
+		Shipping: ShippingConfig{VolumetricDivisor: 5000},
+		CORS: CORSConfig{
+			Public: CORSPolicy{
+				AllowedOrigins: []string{"*"},
+				AllowedMethods: []string{"GET", "HEAD"},
+				AllowedHeaders: []string{"Accept", "Content-Type", "X-Request-ID"},
+				ExposedHeaders: defaultExposedHeaders,
+				MaxAge:         12 * time.Hour,
+			},
+			Private: CORSPolicy{
+				AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+				AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Request-ID"},
+				ExposedHeaders:   defaultExposedHeaders,
+				AllowCredentials: true,
+				MaxAge:           time.Hour,
+			},
+		},
+	}
+}
+
+// defaultExposedHeaders are the response headers API clients read.
+var defaultExposedHeaders = []string{
+	"Content-Length", "X-Request-ID",
+	"Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset",
+}
+
+// Load builds the configuration from, in increasing order of precedence,
+// the defaults, an optional YAML file, the .env file and process
+// environment, and command-line flags.
+func Load(args []string) (*Config, error) {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
+	fs := flag.NewFlagSet("ecommerce-backend", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	env := fs.String("env", "", "environment name (development, staging, production)")
+	port := fs.String("port", "", "HTTP port to listen on")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "env":
+			cfg.Environment = *env
+		case "port":
+			cfg.Server.Port = *port
+		}
+	})
+
+	return cfg, nil
+}
+
+func (cfg *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (cfg *Config) loadEnv() error {
+	setString(&cfg.Environment, "APP_ENV")
+	setString(&cfg.Server.Port, "PORT")
+	setString(&cfg.Server.PublicURL, "PUBLIC_URL")
+	setString(&cfg.Mail.Driver, "MAIL_DRIVER")
+	setString(&cfg.Mail.From, "MAIL_FROM")
+	setString(&cfg.Mail.Dir, "MAIL_DIR")
+	setString(&cfg.Mail.SMTP.Host, "SMTP_HOST")
+	setString(&cfg.Mail.SMTP.Username, "SMTP_USERNAME")
+	setString(&cfg.Mail.SMTP.Password, "SMTP_PASSWORD")
+	if value, ok := os.LookupEnv("SMTP_PORT"); ok && value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("SMTP_PORT: %w", err)
+		}
+		cfg.Mail.SMTP.Port = port
+	}
+	setString(&cfg.Database.URI, "MONGODB_URI")
+	setString(&cfg.Database.Name, "DATABASE_NAME")
+	setString(&cfg.JWT.Secret, "JWT_SECRET")
+	setString(&cfg.JWT.Issuer, "JWT_ISSUER")
+	setString(&cfg.JWT.Audience, "JWT_AUDIENCE")
+	setString(&cfg.Log.Level, "LOG_LEVEL")
+	setString(&cfg.Log.Format, "LOG_FORMAT")
+	setString(&cfg.RateLimit.Store, "RATE_LIMIT_STORE")
+	if value, ok := os.LookupEnv("LOCKOUT_THRESHOLD"); ok && value != "" {
+		threshold, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("LOCKOUT_THRESHOLD: %w", err)
+		}
+		cfg.Lockout.Threshold = threshold
+	}
+	setString(&cfg.Password.Algorithm, "PASSWORD_HASH_ALGORITHM")
+	setString(&cfg.Password.BreachedListFile, "PASSWORD_BREACHED_LIST_FILE")
+	setString(&cfg.TwoFactor.Issuer, "TWO_FACTOR_ISSUER")
+	setString(&cfg.Security.ContentSecurityPolicy, "CONTENT_SECURITY_POLICY")
+	setString(&cfg.Session.CookieName, "SESSION_COOKIE_NAME")
+	setString(&cfg.Session.SameSite, "SESSION_SAME_SITE")
+	setString(&cfg.Tax.Mode, "TAX_MODE")
+	setString(&cfg.Tax.DefaultCountry, "TAX_DEFAULT_COUNTRY")
+	setString(&cfg.Tax.DefaultRegion, "TAX_DEFAULT_REGION")
+	setString(&cfg.Currency.Base, "BASE_CURRENCY")
+	for i := range cfg.OIDC.Providers {
+		provider := &cfg.OIDC.Providers[i]
+		setString(&provider.ClientSecret, "OIDC_"+strings.ToUpper(strings.ReplaceAll(provider.Name, "-", "_"))+"_CLIENT_SECRET")
+	}
+	if value, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		cfg.Server.TrustedProxies = splitList(value)
+	}
+	if value, ok := os.LookupEnv("TWO_FACTOR_REQUIRED_ROLES"); ok {
+		cfg.TwoFactor.RequiredRoles = splitList(value)
+	}
+	for name, policy := range map[string]*CORSPolicy{"PUBLIC": &cfg.CORS.Public, "PRIVATE": &cfg.CORS.Private} {
+		for key, target := range map[string]*[]string{
+			"CORS_" + name + "_ALLOWED_ORIGINS": &policy.AllowedOrigins,
+			"CORS_" + name + "_ALLOWED_METHODS": &policy.AllowedMethods,
+			"CORS_" + name + "_ALLOWED_HEADERS": &policy.AllowedHeaders,
+		} {
+			if value, ok := os.LookupEnv(key); ok {
+				*target = splitList(value)
+			}
+		}
+		key := "CORS_" + name + "_ALLOW_CREDENTIALS"
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			allow, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			policy.AllowCredentials = allow
+		}
+		if err := setDuration(&policy.MaxAge, "CORS_"+name+"_MAX_AGE"); err != nil {
+			return err
+		}
+	}
+	for key, target := range map[string]*int{
+		"PASSWORD_MIN_LENGTH":  &cfg.Password.MinLength,
+		"PASSWORD_BCRYPT_COST": &cfg.Password.BcryptCost,
+	} {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			*target = n
+		}
+	}
+	if value, ok := os.LookupEnv("RATE_LIMIT_ENABLED"); ok && value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("RATE_LIMIT_ENABLED: %w", err)
+		}
+		cfg.RateLimit.Enabled = enabled
+	}
+
+	durations := map[string]*time.Duration{
+		"SERVER_READ_TIMEOUT":        &cfg.Server.ReadTimeout,
+		"SERVER_READ_HEADER_TIMEOUT": &cfg.Server.ReadHeaderTimeout,
+		"SERVER_WRITE_TIMEOUT":       &cfg.Server.WriteTimeout,
+		"SERVER_IDLE_TIMEOUT":        &cfg.Server.IdleTimeout,
+		"SHUTDOWN_TIMEOUT":           &cfg.Server.ShutdownTimeout,
+		"JWT_EXPIRY":                 &cfg.JWT.Expiry,
+		"AUTH_TIMEOUT":               &cfg.Timeouts.Auth,
+		"PRODUCTS_TIMEOUT":           &cfg.Timeouts.Products,
+		"CART_TIMEOUT":               &cfg.Timeouts.Cart,
+		"LOCKOUT_BASE_DURATION":      &cfg.Lockout.BaseDuration,
+		"LOCKOUT_MAX_DURATION":       &cfg.Lockout.MaxDuration,
+		"VERIFICATION_TTL":           &cfg.Account.VerificationTTL,
+		"PASSWORD_RESET_TTL":         &cfg.Account.PasswordResetTTL,
+		"TWO_FACTOR_CHALLENGE_TTL":   &cfg.TwoFactor.ChallengeTTL,
+		"HSTS_MAX_AGE":               &cfg.Security.HSTSMaxAge,
+	}
+	for key, target := range durations {
+		if err := setDuration(target, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setString(target *string, key string) {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		*target = value
+	}
+}
+
+// splitList parses a comma-separated environment value, dropping empty
+// entries.
+func splitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func setDuration(target *time.Duration, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*target = d
+	return nil
+}
+
+// IsHTTPS reports whether the server is reached over HTTPS, which decides
+// whether HSTS is sent and cookies are marked Secure.
+func (s ServerConfig) IsHTTPS() bool {
+	return strings.HasPrefix(strings.ToLower(s.PublicURL), "https://")
+}
+
+// IsProduction reports whether the server runs in the production environment.
+func (cfg *Config) IsProduction() bool {
+	return strings.EqualFold(cfg.Environment, "production")
+}
+
+// Validate checks the configuration for values the server cannot run with.
+// Production deployments must set their own sufficiently long JWT secret.
+func (cfg *Config) Validate() error {
+	var errs []error
+
+	if cfg.Server.Port == "" {
+		errs = append(errs, errors.New("server.port is required"))
+	}
+	if cfg.Server.ReadTimeout <= 0 || cfg.Server.ReadHeaderTimeout <= 0 ||
+		cfg.Server.WriteTimeout <= 0 || cfg.Server.IdleTimeout <= 0 || cfg.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server timeouts must be positive"))
+	}
+	for _, proxy := range cfg.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("server.trusted_proxies: %q is not an IP address or CIDR range", proxy))
+		}
+	}
+	if cfg.Database.URI == "" {
+		errs = append(errs, errors.New("database.uri is required"))
+	}
+	if cfg.Database.Name == "" {
+		errs = append(errs, errors.New("database.name is required"))
+	}
+	if cfg.JWT.Secret == "" && len(cfg.JWT.Keys) == 0 {
+		errs = append(errs, errors.New("jwt.secret or jwt.keys is required"))
+	}
+	if cfg.JWT.Expiry <= 0 {
+		errs = append(errs, errors.New("jwt.expiry must be positive"))
+	}
+	if cfg.JWT.Issuer == "" || cfg.JWT.Audience == "" {
+		errs = append(errs, errors.New("jwt.issuer and jwt.audience are required"))
+	}
+	keyIDs := map[string]bool{}
+	activations := map[time.Time]bool{}
+	for i, key := range cfg.JWT.Keys {
+		if key.ID == "" || key.File == "" {
+			errs = append(errs, fmt.Errorf("jwt.keys[%d] needs an id and a file", i))
+		}
+		if keyIDs[key.ID] {
+			errs = append(errs, fmt.Errorf("jwt key id %q is used twice", key.ID))
+		}
+		if activations[key.ActiveFrom] {
+			errs = append(errs, fmt.Errorf("jwt key %q has the same active_from as another key", key.ID))
+		}
+		keyIDs[key.ID] = true
+		activations[key.ActiveFrom] = true
+	}
+	if cfg.Timeouts.Auth <= 0 || cfg.Timeouts.Products <= 0 || cfg.Timeouts.Cart <= 0 {
+		errs = append(errs, errors.New("timeouts must be positive"))
+	}
+
+	if cfg.Log.Format != "json" && cfg.Log.Format != "text" {
+		errs = append(errs, errors.New(`log.format must be "json" or "text"`))
+	}
+
+	if cfg.RateLimit.Store != "memory" && cfg.RateLimit.Store != "mongo" {
+		errs = append(errs, errors.New(`rate_limit.store must be "memory" or "mongo"`))
+	}
+	for name, limit := range map[string]LimitConfig{
+		"auth_ip":      cfg.RateLimit.AuthIP,
+		"auth_account": cfg.RateLimit.AuthAccount,
+		"writes":       cfg.RateLimit.Writes,
+	} {
+		if limit.Requests <= 0 || limit.Period <= 0 {
+			errs = append(errs, fmt.Errorf("rate_limit.%s needs positive requests and period", name))
+		}
+	}
+
+	if cfg.Lockout.Threshold <= 0 || cfg.Lockout.BaseDuration <= 0 || cfg.Lockout.MaxDuration < cfg.Lockout.BaseDuration {
+		errs = append(errs, errors.New("lockout needs a positive threshold and base_duration <= max_duration"))
+	}
+
+	switch cfg.Mail.Driver {
+	case "smtp":
+		if cfg.Mail.SMTP.Host == "" {
+			errs = append(errs, errors.New("mail.smtp.host is required for the smtp driver"))
+		}
+	case "file":
+		if cfg.Mail.Dir == "" {
+			errs = append(errs, errors.New("mail.dir is required for the file driver"))
+		}
+	case "log":
+	default:
+		errs = append(errs, errors.New(`mail.driver must be "smtp", "file" or "log"`))
+	}
+	// The local drivers deliver nothing and leave verification and reset
+	// tokens readable in logs or on disk
+	if cfg.IsProduction() && cfg.Mail.Driver != "smtp" {
+		errs = append(errs, errors.New(`mail.driver must be "smtp" in production`))
+	}
+	if cfg.Account.VerificationTTL <= 0 || cfg.Account.PasswordResetTTL <= 0 {
+		errs = append(errs, errors.New("account token TTLs must be positive"))
+	}
+
+	if cfg.Password.MinLength < 1 || cfg.Password.MaxLength < cfg.Password.MinLength {
+		errs = append(errs, errors.New("password needs min_length >= 1 and max_length >= min_length"))
+	}
+	switch cfg.Password.Algorithm {
+	case "bcrypt":
+		if cfg.Password.BcryptCost < bcrypt.MinCost || cfg.Password.BcryptCost > bcrypt.MaxCost {
+			errs = append(errs, fmt.Errorf("password.bcrypt_cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+		}
+	case "argon2id":
+		if a := cfg.Password.Argon2; a.Time == 0 || a.MemoryKiB < 8*uint32(a.Threads) || a.Threads == 0 {
+			errs = append(errs, errors.New("password.argon2 needs positive time and threads and memory_kib >= 8*threads"))
+		}
+	default:
+		errs = append(errs, errors.New(`password.algorithm must be "bcrypt" or "argon2id"`))
+	}
+
+	if cfg.TwoFactor.Issuer == "" || cfg.TwoFactor.ChallengeTTL <= 0 {
+		errs = append(errs, errors.New("two_factor needs an issuer and a positive challenge_ttl"))
+	}
+
+	seen := map[string]bool{}
+	for i, p := range cfg.OIDC.Providers {
+		if p.Name == "" || p.Issuer == "" || p.ClientID == "" {
+			errs = append(errs, fmt.Errorf("oidc.providers[%d] needs a name, issuer and client_id", i))
+		}
+		if seen[p.Name] {
+			errs = append(errs, fmt.Errorf("oidc provider %q is configured twice", p.Name))
+		}
+		seen[p.Name] = true
+	}
+
+	if cfg.Security.CSRFCookieName == "" || cfg.Security.CSRFHeaderName == "" {
+		errs = append(errs, errors.New("security.csrf_cookie_name and security.csrf_header_name are required"))
+	}
+	if cfg.Security.HSTSMaxAge < 0 {
+		errs = append(errs, errors.New("security.hsts_max_age must not be negative"))
+	}
+	if cfg.Session.CookieName == "" || cfg.Session.CookieName == cfg.Security.CSRFCookieName {
+		errs = append(errs, errors.New("session.cookie_name is required and must differ from security.csrf_cookie_name"))
+	}
+	if cfg.Session.SameSite != "lax" && cfg.Session.SameSite != "strict" {
+		errs = append(errs, errors.New(`session.same_site must be "lax" or "strict"`))
+	}
+	if cfg.IsProduction() && !cfg.Server.IsHTTPS() {
+		log.Println("Warning: server.public_url is not https; session cookies will not be marked Secure")
+	}
+
+	if cfg.Tax.Mode != "exclusive" && cfg.Tax.Mode != "inclusive" {
+		errs = append(errs, errors.New(`tax.mode must be "exclusive" or "inclusive"`))
+	}
+	if cfg.Tax.DefaultClass == "" {
+		errs = append(errs, errors.New("tax.default_class is required"))
+	}
+	if cfg.Tax.DefaultCountry != "" && !validCountryCode(cfg.Tax.DefaultCountry) {
+		errs = append(errs, errors.New("tax.default_country must be a two-letter upper-case country code"))
+	}
+	for i, rate := range cfg.Tax.Rates {
+		if rate.Name == "" || rate.Class == "" || !validCountryCode(rate.Country) {
+			errs = append(errs, fmt.Errorf("tax.rates[%d] needs a name, class and two-letter upper-case country code", i))
+		}
+		if rate.Percent < 0 || rate.Percent > 100 {
+			errs = append(errs, fmt.Errorf("tax.rates[%d].percent must be between 0 and 100", i))
+		}
+	}
+
+	if !money.IsCurrency(cfg.Currency.Base) {
+		errs = append(errs, fmt.Errorf("currency.base %q is not a supported ISO 4217 currency code", cfg.Currency.Base))
+	}
+
+	errs = append(errs, validateShipping(cfg.Shipping, cfg.Currency.Base)...)
+
+	for name, policy := range map[string]CORSPolicy{"public": cfg.CORS.Public, "private": cfg.CORS.Private} {
+		for _, origin := range policy.AllowedOrigins {
+			if origin == "*" && policy.AllowCredentials {
+				errs = append(errs, fmt.Errorf(`cors.%s cannot allow credentials for origin "*"`, name))
+			} else if origin != "*" && !validOriginPattern(origin) {
+				errs = append(errs, fmt.Errorf("cors.%s: %q is not an origin such as https://shop.example.com or https://*.example.com", name, origin))
+			}
+		}
+		if len(policy.AllowedMethods) == 0 {
+			errs = append(errs, fmt.Errorf("cors.%s.allowed_methods is required", name))
+		}
+		if slices.Contains(policy.AllowedHeaders, "*") {
+			errs = append(errs, fmt.Errorf(`cors.%s.allowed_headers must list headers instead of "*"`, name))
+		}
+	}
+
+	switch {
+	case len(cfg.JWT.Keys) > 0:
+		// Asymmetric keys replace the shared secret
+	case cfg.IsProduction():
+		if cfg.JWT.Secret == defaultJWTSecret {
+			errs = append(errs, errors.New("jwt.secret must not use the default value in production"))
+		} else if len(cfg.JWT.Secret) < minProductionSecretLength {
+			errs = append(errs, fmt.Errorf("jwt.secret must be at least %d characters in production", minProductionSecretLength))
+		}
+	case cfg.JWT.Secret == defaultJWTSecret:
+		log.Println("Warning: using the default JWT secret; set JWT_SECRET or jwt.keys before deploying")
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateShipping checks that every country is in at most one zone and
+// that the methods of each zone are complete and uniquely coded.
+func validateShipping(cfg ShippingConfig, base string) []error {
+	var errs []error
+	if cfg.VolumetricDivisor < 0 {
+		errs = append(errs, errors.New("shipping.volumetric_divisor must not be negative"))
+	}
+
+	zoneOf := map[string]string{}
+	catchAll := ""
+	for i, zone := range cfg.Zones {
+		if zone.Name == "" {
+			errs = append(errs, fmt.Errorf("shipping.zones[%d] needs a name", i))
+		}
+		if len(zone.Countries) == 0 {
+			if catchAll != "" {
+				errs = append(errs, fmt.Errorf("shipping zones %q and %q both cover every other country", catchAll, zone.Name))
+			}
+			catchAll = zone.Name
+		}
+		for _, country := range zone.Countries {
+			if !validCountryCode(country) {
+				errs = append(errs, fmt.Errorf("shipping zone %q: %q is not a two-letter upper-case country code", zone.Name, country))
+			} else if other, ok := zoneOf[country]; ok {
+				errs = append(errs, fmt.Errorf("shipping zones %q and %q both list %s", other, zone.Name, country))
+			}
+			zoneOf[country] = zone.Name
+		}
+
+		codes := map[string]bool{}
+		for j, method := range zone.Methods {
+			if method.Code == "" || method.Name == "" {
+				errs = append(errs, fmt.Errorf("shipping zone %q: methods[%d] needs a code and name", zone.Name, j))
+			} else if codes[method.Code] {
+				errs = append(errs, fmt.Errorf("shipping zone %q lists method %q twice", zone.Name, method.Code))
+			}
+			codes[method.Code] = true
+			if method.Type != "flat" && method.Type != "weight" {
+				errs = append(errs, fmt.Errorf(`shipping zone %q: method %q type must be "flat" or "weight"`, zone.Name, method.Code))
+			}
+			if !method.valid() || method.MaxWeight < 0 {
+				errs = append(errs, fmt.Errorf("shipping zone %q: method %q amounts must not be negative", zone.Name, method.Code))
+			}
+			for currency, amounts := range method.Currencies {
+				if !money.IsCurrency(currency) || currency == base {
+					errs = append(errs, fmt.Errorf("shipping zone %q: method %q: %q is not a supported currency other than %s", zone.Name, method.Code, currency, base))
+				}
+				if !amounts.valid() {
+					errs = append(errs, fmt.Errorf("shipping zone %q: method %q %s amounts must not be negative", zone.Name, method.Code, currency))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// validCountryCode reports whether code looks like an ISO 3166-1 alpha-2
+// code.
+func validCountryCode(code string) bool {
+	return len(code) == 2 && strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+}
+
+// validOriginPattern reports whether pattern is scheme://host[:port] with
+// nothing else, where the host may start with a "*." wildcard label.
+func validOriginPattern(pattern string) bool {
+	u, err := url.Parse(strings.Replace(pattern, "://*.", "://wildcard.", 1))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	return u.Path == "" && u.RawQuery == "" && u.Fragment == "" && u.User == nil &&
+		!strings.Contains(u.Host, "*") && strings.Count(pattern, "*") <= 1
+}
+
+// Redacted returns a copy of the configuration with secrets masked.
+func (cfg *Config) Redacted() *Config {
+	out := *cfg
+	if out.JWT.Secret != "" {
+		out.JWT.Secret = redacted
+	}
+	if out.Mail.SMTP.Password != "" {
+		out.Mail.SMTP.Password = redacted
+	}
+	out.OIDC.Providers = slices.Clone(out.OIDC.Providers)
+	for i := range out.OIDC.Providers {
+		if out.OIDC.Providers[i].ClientSecret != "" {
+			out.OIDC.Providers[i].ClientSecret = redacted
+		}
+	}
+	if u, err := url.Parse(out.Database.URI); err == nil {
+		out.Database.URI = u.Redacted()
+	} else {
+		out.Database.URI = redacted
+	}
+	return &out
+}
+
+// Print writes the effective configuration as YAML with secrets redacted.
+func (cfg *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
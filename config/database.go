@@ -2,44 +2,76 @@ package config
 
 import (
 	"context"
+	"fmt"
 	"log"
-	"os"
 	"time"
 
+	"ecommerce-backend/metrics"
+
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var DB *mongo.Database
-
-func ConnectDB() {
-	mongoURI := os.Getenv("MONGODB_URI")
-	if mongoURI == "" {
-		mongoURI = "mongodb://localhost:27017"
-	}
-
-	dbName := os.Getenv("DATABASE_NAME")
-	if dbName == "" {
-		dbName = "ecommerce_db"
-	}
+var (
+	Client *mongo.Client
+	DB     *mongo.Database
+)
 
+func ConnectDB(cfg DatabaseConfig) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI).SetMonitor(metrics.NewMongoMonitor()))
 	if err != nil {
-		log.Fatal("Failed to connect to MongoDB:", err)
+		return fmt.Errorf("connecting to MongoDB: %w", err)
 	}
 
 	// Test connection
 	if err := client.Ping(ctx, nil); err != nil {
-		log.Fatal("Failed to ping MongoDB:", err)
+		_ = client.Disconnect(context.Background())
+		return fmt.Errorf("pinging MongoDB: %w", err)
 	}
 
-	DB = client.Database(dbName)
+	Client = client
+	DB = client.Database(cfg.Name)
 	log.Println("Connected to MongoDB successfully")
+	return nil
+}
+
+// DisconnectDB closes the MongoDB client, waiting for in-use connections
+// to be returned to the pool until ctx expires.
+func DisconnectDB(ctx context.Context) error {
+	if Client == nil {
+		return nil
+	}
+	if err := Client.Disconnect(ctx); err != nil {
+		return fmt.Errorf("disconnecting from MongoDB: %w", err)
+	}
+	Client = nil
+	DB = nil
+	log.Println("Disconnected from MongoDB")
+	return nil
 }
 
 func GetCollection(collectionName string) *mongo.Collection {
 	return DB.Collection(collectionName)
 }
+
+// NextSequence atomically increments and returns the named counter stored
+// in the counters collection, creating it on first use.
+func NextSequence(ctx context.Context, name string) (int64, error) {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := GetCollection("counters").FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": name},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return 0, fmt.Errorf("incrementing %s sequence: %w", name, err)
+	}
+	return counter.Seq, nil
+}
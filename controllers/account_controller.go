@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
+	"ecommerce-backend/logger"
+	"ecommerce-backend/mailer"
+	"ecommerce-backend/models"
+	"ecommerce-backend/password"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// sendVerificationEmail issues a token for purpose and emails it to
+// address: the account email for new users, or the pending address when
+// the email is being changed. Delivery failures are logged.
+func sendVerificationEmail(ctx context.Context, cfg *config.Config, mail mailer.Mailer, user models.User, address, purpose string) {
+	log := logger.FromContext(ctx)
+
+	token, err := issueUserToken(ctx, user.ID, purpose, cfg.Account.VerificationTTL)
+	if err != nil {
+		log.Error("issuing verification token failed", "error", err)
+		return
+	}
+
+	link := cfg.Server.PublicURL + "/verify-email?token=" + url.QueryEscape(token)
+	err = mail.Send(ctx, mailer.Message{
+		To:      address,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening the link below:\n\n%s\n\n"+
+			"Or send this token to POST /api/auth/verify-email: %s\n\nThe link expires in %s.\n",
+			user.Name, link, token, cfg.Account.VerificationTTL),
+	})
+	if err != nil {
+		log.Error("sending verification email failed", "error", err)
+	}
+}
+
+// VerifyEmail confirms either a new account's email or a pending email
+// change, depending on which kind of token was sent.
+func VerifyEmail(c *gin.Context) {
+	var req dto.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	token, err := consumeUserToken(ctx, req.Token, models.TokenVerifyEmail, models.TokenChangeEmail)
+	if errors.Is(err, errInvalidToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		respondDBError(c, err, "Failed to verify email")
+		return
+	}
+
+	var update any = bson.M{"$set": bson.M{"email_verified": true, "updated_at": time.Now()}}
+	filter := bson.M{"_id": token.UserID}
+
+	if token.Purpose == models.TokenChangeEmail {
+		// Move the pending address into place in one update
+		update = bson.A{
+			bson.M{"$set": bson.M{"email": "$pending_email", "email_verified": true, "updated_at": time.Now()}},
+			bson.M{"$unset": "pending_email"},
+		}
+		filter["pending_email"] = bson.M{"$exists": true}
+	}
+
+	result, err := userCollection().UpdateOne(ctx, filter, update)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already in use"})
+		return
+	}
+	if err != nil {
+		respondDBError(c, err, "Failed to verify email")
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.MessageResponse{Message: "Email verified successfully"})
+}
+
+// ForgotPassword emails a password reset link. It responds the same way
+// whether or not the account exists so it cannot be used to probe emails.
+func ForgotPassword(cfg *config.Config, mail mailer.Mailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.ForgotPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx := c.Request.Context()
+		response := dto.MessageResponse{Message: "If an account exists for this email, a reset link has been sent"}
+
+		var user models.User
+		err := userCollection().FindOne(ctx, bson.M{"email": req.Email}).Decode(&user)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch user")
+			return
+		}
+
+		token, err := issueUserToken(ctx, user.ID, models.TokenResetPassword, cfg.Account.PasswordResetTTL)
+		if err != nil {
+			respondDBError(c, err, "Failed to create reset token")
+			return
+		}
+
+		link := cfg.Server.PublicURL + "/reset-password?token=" + url.QueryEscape(token)
+		err = mail.Send(ctx, mailer.Message{
+			To:      user.Email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf("Hi %s,\n\nReset your password by opening the link below:\n\n%s\n\n"+
+				"Or send this token to POST /api/auth/reset-password: %s\n\nThe link expires in %s. "+
+				"If you did not ask for a reset, you can ignore this email.\n",
+				user.Name, link, token, cfg.Account.PasswordResetTTL),
+		})
+		if err != nil {
+			_ = c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send reset email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// ResetPassword sets a new password using an emailed reset token. The
+// token is only used up once the new password passes the policy.
+func ResetPassword(passwords *password.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.ResetPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx := c.Request.Context()
+
+		pending, err := findUserToken(ctx, req.Token, models.TokenResetPassword)
+		if errors.Is(err, errInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to reset password")
+			return
+		}
+
+		var user models.User
+		if err := userCollection().FindOne(ctx, bson.M{"_id": pending.UserID}).Decode(&user); err != nil {
+			respondDBError(c, err, "Failed to reset password")
+			return
+		}
+
+		hashedPassword, ok := hashNewPassword(c, passwords, req.Password, user.Email)
+		if !ok {
+			return
+		}
+
+		token, err := consumeUserToken(ctx, req.Token, models.TokenResetPassword)
+		if errors.Is(err, errInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to reset password")
+			return
+		}
+
+		// Receiving the email proves ownership of the address. A reset also
+		// lifts any lockout and signs out every existing session.
+		now := time.Now()
+		_, err = userCollection().UpdateOne(ctx, bson.M{"_id": token.UserID}, bson.M{
+			"$set": bson.M{
+				"password":              hashedPassword,
+				"email_verified":        true,
+				"failed_login_attempts": 0,
+				"lockout_count":         0,
+				"sessions_valid_after":  now,
+				"updated_at":            now,
+			},
+			"$unset": bson.M{"locked_until": ""},
+		})
+		if err != nil {
+			respondDBError(c, err, "Failed to reset password")
+			return
+		}
+
+		if err := revokeUserTokens(ctx, token.UserID, models.TokenResetPassword); err != nil {
+			logger.FromContext(ctx).Warn("revoking reset tokens failed", "error", err)
+		}
+
+		c.JSON(http.StatusOK, dto.MessageResponse{Message: "Password reset successfully"})
+	}
+}
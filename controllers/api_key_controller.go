@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"ecommerce-backend/apikey"
+	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
+	"ecommerce-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func apiKeyCollection() *mongo.Collection {
+	return config.GetCollection("api_keys")
+}
+
+// CreateAPIKey issues a scoped API key. The key is only returned here.
+func CreateAPIKey(c *gin.Context) {
+	var req dto.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, prefix, err := apikey.Generate()
+	if err != nil {
+		_ = c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	createdBy, _ := primitive.ObjectIDFromHex(c.GetString("user_id"))
+	record := models.APIKey{
+		ID:        primitive.NewObjectID(),
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   apikey.Hash(key),
+		Scopes:    req.Scopes,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	if _, err := apiKeyCollection().InsertOne(c.Request.Context(), record); err != nil {
+		respondDBError(c, err, "Failed to create API key")
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.CreatedAPIKeyResponse{APIKey: dto.NewAPIKey(record), Key: key})
+}
+
+// ListAPIKeys lists all API keys, newest first, including revoked ones.
+func ListAPIKeys(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	cursor, err := apiKeyCollection().Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		respondDBError(c, err, "Failed to fetch API keys")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var keys []models.APIKey
+	if err = cursor.All(ctx, &keys); err != nil {
+		respondDBError(c, err, "Failed to decode API keys")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewAPIKeysResponse(keys))
+}
+
+// RevokeAPIKey stops a key from authenticating. Revoked keys stay listed.
+func RevokeAPIKey(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	result, err := apiKeyCollection().UpdateOne(c.Request.Context(),
+		bson.M{"_id": objectID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		respondDBError(c, err, "Failed to revoke API key")
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found or already revoked"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.MessageResponse{Message: "API key revoked"})
+}
@@ -2,122 +2,296 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"math"
 	"net/http"
-	"os"
+	"strconv"
 	"time"
 
 	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
+	"ecommerce-backend/logger"
+	"ecommerce-backend/mailer"
+	"ecommerce-backend/metrics"
+	"ecommerce-backend/middleware"
 	"ecommerce-backend/models"
+	"ecommerce-backend/password"
+	"ecommerce-backend/tokens"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/crypto/bcrypt"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var userCollection = config.GetCollection("users")
+func userCollection() *mongo.Collection {
+	return config.GetCollection("users")
+}
 
-func Register(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+func Register(cfg *config.Config, mail mailer.Mailer, passwords *password.Manager, keys *tokens.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.RegisterRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		hashedPassword, ok := hashNewPassword(c, passwords, req.Password, req.Email)
+		if !ok {
+			return
+		}
+
+		ctx := c.Request.Context()
+
+		// Check if user already exists
+		var existingUser models.User
+		err := userCollection().FindOne(ctx, bson.M{"email": req.Email}).Decode(&existingUser)
+		if err == nil {
+			metrics.AuthResult("register", false)
+			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+			return
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			respondDBError(c, err, "Failed to check existing user")
+			return
+		}
+
+		now := time.Now()
+		user := models.User{
+			ID:            primitive.NewObjectID(),
+			Email:         req.Email,
+			Password:      hashedPassword,
+			Name:          req.Name,
+			EmailVerified: false,
+			Role:          models.RoleCustomer,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+
+		_, err = userCollection().InsertOne(ctx, user)
+		if mongo.IsDuplicateKeyError(err) {
+			metrics.AuthResult("register", false)
+			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to create user")
+			return
+		}
+
+		sendVerificationEmail(ctx, cfg, mail, user, user.Email, models.TokenVerifyEmail)
+
+		// Generate JWT token
+		token, err := keys.Issue(user.ID.Hex())
+		if err != nil {
+			_ = c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		metrics.AuthResult("register", true)
+		c.JSON(http.StatusCreated, dto.AuthResponse{
+			Token: token,
+			User:  dto.NewUser(user),
+		})
 	}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+func Login(cfg *config.Config, passwords *password.Manager, keys *tokens.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var loginReq dto.LoginRequest
+		if err := c.ShouldBindJSON(&loginReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !checkLoginCSRF(c, cfg, loginReq.Mode) {
+			return
+		}
 
-	// Check if user already exists
-	var existingUser models.User
-	err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&existingUser)
-	if err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
-		return
+		ctx := c.Request.Context()
+
+		var user models.User
+		err := userCollection().FindOne(ctx, bson.M{"email": loginReq.Email}).Decode(&user)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			metrics.AuthResult("login", false)
+			recordLoginEvent(c, models.LoginEvent{Email: loginReq.Email, Reason: models.LoginReasonUnknownUser})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch user")
+			return
+		}
+
+		// Reject locked accounts before checking the password
+		if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+			failedLogin(c, user, models.LoginReasonLocked, user.LockedUntil)
+			return
+		}
+
+		// Check password
+		ok, needsRehash := passwords.Verify(user.Password, loginReq.Password)
+		if !ok {
+			lockedUntil, err := recordFailedLogin(ctx, user.ID, cfg.Lockout)
+			if err != nil {
+				respondDBError(c, err, "Failed to record login attempt")
+				return
+			}
+			failedLogin(c, user, models.LoginReasonInvalidPassword, lockedUntil)
+			return
+		}
+
+		if needsRehash {
+			upgradePasswordHash(ctx, passwords, user, loginReq.Password)
+		}
+
+		// With two-factor authentication the password only earns a
+		// challenge; the token is issued by VerifyLoginChallenge
+		if user.TwoFactorEnabled {
+			issueLoginChallenge(c, cfg, user)
+			return
+		}
+
+		completeLogin(c, cfg, keys, user, loginReq.Mode)
 	}
+}
 
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+// failedLogin records a failed login and responds with 401, or with 423
+// and Retry-After when the account is locked.
+func failedLogin(c *gin.Context, user models.User, reason string, lockedUntil *time.Time) {
+	metrics.AuthResult("login", false)
+	recordLoginEvent(c, models.LoginEvent{UserID: user.ID, Email: user.Email, Reason: reason})
+	if lockedUntil != nil {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(*lockedUntil).Seconds()))))
+		c.JSON(http.StatusLocked, gin.H{"error": "Account temporarily locked due to too many failed login attempts"})
 		return
 	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+}
 
-	user.ID = primitive.NewObjectID()
-	user.Password = string(hashedPassword)
-	user.CreatedAt = time.Now()
-	user.UpdatedAt = time.Now()
+// completeLogin clears the lockout state, records the login and responds
+// with a token once every login step has passed.
+func completeLogin(c *gin.Context, cfg *config.Config, keys *tokens.Keyring, user models.User, mode string) {
+	token, ok := startSession(c, keys, user)
+	if !ok {
+		return
+	}
 
-	_, err = userCollection.InsertOne(ctx, user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+	if mode == dto.LoginModeCookie {
+		setSessionCookie(c, cfg, token)
+		c.JSON(http.StatusOK, dto.SessionResponse{User: dto.NewUser(user)})
 		return
 	}
+	c.JSON(http.StatusOK, dto.AuthResponse{
+		Token: token,
+		User:  dto.NewUser(user),
+	})
+}
+
+// startSession clears the lockout state, records the login and issues
+// the access token. On failure it writes the response and returns false.
+func startSession(c *gin.Context, keys *tokens.Keyring, user models.User) (string, bool) {
+	ctx := c.Request.Context()
+
+	anomalies := loginAnomalies(ctx, user, c.ClientIP(), c.Request.UserAgent())
+	if err := resetLoginFailures(ctx, user.ID); err != nil {
+		respondDBError(c, err, "Failed to update user")
+		return "", false
+	}
+	recordLoginEvent(c, models.LoginEvent{UserID: user.ID, Email: user.Email, Success: true, Anomalies: anomalies})
 
 	// Generate JWT token
-	token, err := generateToken(user.ID.Hex())
+	token, err := keys.Issue(user.ID.Hex())
 	if err != nil {
+		_ = c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
+		return "", false
 	}
 
-	user.Password = "" // Don't return password
-	c.JSON(http.StatusCreated, models.AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	metrics.AuthResult("login", true)
+	return token, true
 }
 
-func Login(c *gin.Context) {
-	var loginReq models.LoginRequest
-	if err := c.ShouldBindJSON(&loginReq); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// checkLoginCSRF requires the CSRF token for cookie-mode logins, so another
+// site can't sign the browser in to an account of its choosing. On failure
+// it writes the response and returns false.
+func checkLoginCSRF(c *gin.Context, cfg *config.Config, mode string) bool {
+	if mode == dto.LoginModeCookie && !middleware.CheckCSRFToken(c, cfg.Security) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Missing or invalid CSRF token"})
+		return false
 	}
+	return true
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// setSessionCookie stores an access token in the HttpOnly session cookie.
+func setSessionCookie(c *gin.Context, cfg *config.Config, token string) {
+	c.SetSameSite(sameSite(cfg.Session.SameSite))
+	c.SetCookie(cfg.Session.CookieName, token, int(cfg.JWT.Expiry.Seconds()), "/", "", cfg.Server.IsHTTPS(), true)
+}
 
-	var user models.User
-	err := userCollection.FindOne(ctx, bson.M{"email": loginReq.Email}).Decode(&user)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-		return
+func clearSessionCookie(c *gin.Context, cfg *config.Config) {
+	c.SetSameSite(sameSite(cfg.Session.SameSite))
+	c.SetCookie(cfg.Session.CookieName, "", -1, "/", "", cfg.Server.IsHTTPS(), true)
+}
+
+func sameSite(value string) http.SameSite {
+	if value == "strict" {
+		return http.SameSiteStrictMode
 	}
+	return http.SameSiteLaxMode
+}
 
-	// Check password
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginReq.Password))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-		return
+// Logout clears the session cookie. Bearer tokens are not affected; clients
+// using them simply discard the token.
+func Logout(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cookie, err := c.Cookie(cfg.Session.CookieName); err == nil && cookie != "" &&
+			!middleware.CheckCSRFToken(c, cfg.Security) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Missing or invalid CSRF token"})
+			return
+		}
+		clearSessionCookie(c, cfg)
+		c.JSON(http.StatusOK, dto.MessageResponse{Message: "Signed out"})
 	}
+}
 
-	// Generate JWT token
-	token, err := generateToken(user.ID.Hex())
+// upgradePasswordHash re-hashes a password whose stored hash uses an older
+// algorithm or weaker parameters. Failures are logged; the old hash keeps
+// working until the next login.
+func upgradePasswordHash(ctx context.Context, passwords *password.Manager, user models.User, plaintext string) {
+	hash, err := passwords.Hash(plaintext)
+	if err == nil {
+		// Match the old hash so a concurrent password change is not undone
+		_, err = userCollection().UpdateOne(ctx,
+			bson.M{"_id": user.ID, "password": user.Password},
+			bson.M{"$set": bson.M{"password": hash}},
+		)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
+		logger.FromContext(ctx).Warn("upgrading password hash failed", "error", err)
 	}
-
-	user.Password = "" // Don't return password
-	c.JSON(http.StatusOK, models.AuthResponse{
-		Token: token,
-		User:  user,
-	})
 }
 
-func generateToken(userID string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days
-		"iat":     time.Now().Unix(),
+// JWKS publishes the public keys of the token keyring so other services
+// can verify our access tokens.
+func JWKS(keys *tokens.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, keys.JWKS())
 	}
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "default-secret-key"
+// CSRFToken returns the double-submit token browsers using cookie sessions
+// send with state-changing requests, setting the CSRF cookie if needed.
+func CSRFToken(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := middleware.IssueCSRFToken(c, cfg.Security, cfg.Server.IsHTTPS())
+		if err != nil {
+			_ = c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue CSRF token"})
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, dto.CSRFTokenResponse{CSRFToken: token})
 	}
-
-	return token.SignedString([]byte(secret))
 }
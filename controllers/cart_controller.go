@@ -2,18 +2,34 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
+	"ecommerce-backend/logger"
+	"ecommerce-backend/metrics"
 	"ecommerce-backend/models"
+	"ecommerce-backend/money"
+	"ecommerce-backend/pricing"
+	"ecommerce-backend/promotions"
+	"ecommerce-backend/tax"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var cartCollection = config.GetCollection("cart")
+// maxCartQuantity caps how many units of one product a cart can hold.
+const maxCartQuantity = 999
+
+func cartCollection() *mongo.Collection {
+	return config.GetCollection("cart")
+}
 
 func AddToCart(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -22,68 +38,111 @@ func AddToCart(c *gin.Context) {
 		return
 	}
 
-	var cartItem models.Cart
-	if err := c.ShouldBindJSON(&cartItem); err != nil {
+	var req dto.AddToCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	productID, err := primitive.ObjectIDFromHex(req.ProductID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
 	userObjectID, _ := primitive.ObjectIDFromHex(userID.(string))
-	cartItem.UserID = userObjectID
-	cartItem.ID = primitive.NewObjectID()
-	cartItem.CreatedAt = time.Now()
-	cartItem.UpdatedAt = time.Now()
+	now := time.Now()
+	cartItem := models.Cart{
+		ID:        primitive.NewObjectID(),
+		UserID:    userObjectID,
+		ProductID: productID,
+		Quantity:  req.Quantity,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	ctx := c.Request.Context()
 
 	// Check if item already exists in cart
 	var existingItem models.Cart
-	err := cartCollection.FindOne(ctx, bson.M{
+	err = cartCollection().FindOne(ctx, bson.M{
 		"user_id":    cartItem.UserID,
 		"product_id": cartItem.ProductID,
 	}).Decode(&existingItem)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		respondDBError(c, err, "Failed to fetch cart")
+		return
+	}
 
 	if err == nil {
-		// Update quantity if item exists
-		_, err = cartCollection.UpdateOne(
+		// Update quantity if item exists, unless that would go over the cap
+		result, err := cartCollection().UpdateOne(
 			ctx,
-			bson.M{"_id": existingItem.ID},
+			bson.M{"_id": existingItem.ID, "quantity": bson.M{"$lte": maxCartQuantity - cartItem.Quantity}},
 			bson.M{"$inc": bson.M{"quantity": cartItem.Quantity}, "$set": bson.M{"updated_at": time.Now()}},
 		)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart"})
+			respondDBError(c, err, "Failed to update cart")
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "Cart updated successfully"})
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A cart can hold at most %d of a product", maxCartQuantity)})
+			return
+		}
+		c.JSON(http.StatusOK, dto.MessageResponse{Message: "Cart updated successfully"})
 		return
 	}
 
 	// Add new item to cart
-	_, err = cartCollection.InsertOne(ctx, cartItem)
+	_, err = cartCollection().InsertOne(ctx, cartItem)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add to cart"})
+		respondDBError(c, err, "Failed to add to cart")
 		return
 	}
 
-	c.JSON(http.StatusCreated, cartItem)
+	// The first item in an empty cart starts a new cart
+	count, err := cartCollection().CountDocuments(ctx, bson.M{"user_id": cartItem.UserID})
+	if err != nil {
+		logger.FromContext(ctx).Warn("counting cart items failed", "error", err)
+	} else if count == 1 {
+		metrics.CartsCreated.Inc()
+	}
+
+	c.JSON(http.StatusCreated, dto.NewCartEntry(cartItem))
 }
 
-func GetCart(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
+// GetCart returns the cart with discounts, tax and totals. Tax is worked
+// out for the country and region in the query, or the configured default.
+func GetCart(cfg *config.Config, taxes tax.Calculator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
 
-	userObjectID, _ := primitive.ObjectIDFromHex(userID.(string))
+		pricer, ok := cartPricerFromQuery(c, cfg, taxes)
+		if !ok {
+			return
+		}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+		userObjectID, _ := primitive.ObjectIDFromHex(userID.(string))
 
+		cart, err := pricer.priceCart(c.Request.Context(), userObjectID)
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch cart")
+			return
+		}
+
+		c.JSON(http.StatusOK, cart.response())
+	}
+}
+
+// findCartItems returns the user's cart lines joined with their products.
+func findCartItems(ctx context.Context, userID primitive.ObjectID) ([]models.CartItem, error) {
 	// Aggregation pipeline to join cart with products
 	pipeline := []bson.M{
-		{"$match": bson.M{"user_id": userObjectID}},
+		{"$match": bson.M{"user_id": userID}},
 		{"$lookup": bson.M{
 			"from":         "products",
 			"localField":   "product_id",
@@ -93,20 +152,140 @@ func GetCart(c *gin.Context) {
 		{"$unwind": "$product"},
 	}
 
-	cursor, err := cartCollection.Aggregate(ctx, pipeline)
+	cursor, err := cartCollection().Aggregate(ctx, pipeline)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart"})
-		return
+		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var cartItems []bson.M
+	var cartItems []models.CartItem
 	if err = cursor.All(ctx, &cartItems); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode cart items"})
-		return
+		return nil, err
+	}
+	return cartItems, nil
+}
+
+// cartPricer prices carts for an address in the currency asked for.
+type cartPricer struct {
+	taxes   tax.Calculator
+	address tax.Address
+	want    string // currency asked for
+	base    string
+	rates   money.Rates
+}
+
+func newCartPricer(ctx context.Context, cfg *config.Config, taxes tax.Calculator, address tax.Address, want string) (cartPricer, error) {
+	rates, err := loadRates(ctx, cfg.Currency.Base)
+	if err != nil {
+		return cartPricer{}, err
+	}
+	if want == "" {
+		want = cfg.Currency.Base
+	}
+	return cartPricer{taxes: taxes, address: address, want: strings.ToUpper(want), base: cfg.Currency.Base, rates: rates}, nil
+}
+
+// cartPricerFromQuery prices the cart for the address and currency in the
+// query, defaulting to the configured tax address and the base currency.
+func cartPricerFromQuery(c *gin.Context, cfg *config.Config, taxes tax.Calculator) (cartPricer, bool) {
+	var query dto.CartQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return cartPricer{}, false
+	}
+
+	address := tax.Address{Country: query.Country, Region: query.Region}
+	if query.Country == "" {
+		address = tax.Address{Country: cfg.Tax.DefaultCountry, Region: cfg.Tax.DefaultRegion}
+	}
+
+	pricer, err := newCartPricer(c.Request.Context(), cfg, taxes, address, query.Currency)
+	if err != nil {
+		respondDBError(c, err, "Failed to fetch exchange rates")
+		return cartPricer{}, false
+	}
+	return pricer, true
+}
+
+// currency returns the currency items are priced in: the one asked for
+// when every item has a price in it, otherwise the base currency.
+func (p cartPricer) currency(items []models.CartItem) string {
+	return pricing.Currency(items, p.want, p.base)
+}
+
+// pricedCart is a user's cart with its coupon evaluated and its prices
+// worked out.
+type pricedCart struct {
+	items     []models.CartItem
+	coupon    *dto.AppliedCoupon
+	promotion *models.Promotion // nil without a coupon that applies
+	discount  promotions.Result
+	quote     pricing.Quote
+	display   *money.Money // the total in the currency asked for, when the cart is priced in another
+}
+
+// price prices items with a promotion that applies, or without one when
+// promotion is nil.
+func (p cartPricer) price(items []models.CartItem, promotion *models.Promotion, discount promotions.Result) (pricedCart, error) {
+	quote, err := pricing.Price(items, p.currency(items), discount.LineDiscounts, p.taxes, p.address)
+	if err != nil {
+		return pricedCart{}, err
 	}
 
-	c.JSON(http.StatusOK, gin.H{"cart": cartItems})
+	cart := pricedCart{items: items, promotion: promotion, discount: discount, quote: quote}
+	if promotion != nil {
+		cart.coupon = &dto.AppliedCoupon{Code: promotion.Code, Valid: true}
+	}
+	if quote.Currency != p.want {
+		if total, ok := p.rates.Convert(quote.Total, p.want); ok {
+			cart.display = &total
+		}
+	}
+	return cart, nil
+}
+
+// priceCart prices the user's cart, applying their coupon if it is still
+// valid.
+func (p cartPricer) priceCart(ctx context.Context, userID primitive.ObjectID) (pricedCart, error) {
+	items, err := findCartItems(ctx, userID)
+	if err != nil {
+		return pricedCart{}, err
+	}
+
+	var coupon models.CartCoupon
+	err = cartCouponCollection().FindOne(ctx, bson.M{"_id": userID}).Decode(&coupon)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return p.price(items, nil, promotions.Result{})
+	}
+	if err != nil {
+		return pricedCart{}, err
+	}
+
+	promotion, discount, err := evaluateCoupon(ctx, userID, bson.M{"_id": coupon.PromotionID}, items, p.currency(items), p.rates)
+	var rejected *promotions.RejectedError
+	if errors.As(err, &rejected) {
+		cart, err := p.price(items, nil, promotions.Result{})
+		if err == nil {
+			cart.coupon = &dto.AppliedCoupon{Code: coupon.Code, Message: rejected.Reason}
+		}
+		return cart, err
+	}
+	if err != nil {
+		return pricedCart{}, err
+	}
+	return p.price(items, &promotion, discount)
+}
+
+func (cart pricedCart) response() dto.CartResponse {
+	response := dto.NewCartResponse(cart.items, cart.quote)
+	response.Coupon = cart.coupon
+	response.Totals.DisplayTotal = cart.display
+	if cart.promotion != nil {
+		response.Totals.FreeShipping = cart.discount.FreeShipping
+		response.Totals.Discounts = append(response.Totals.Discounts,
+			dto.NewCartDiscount(cart.promotion.Code, cart.promotion.Description, cart.discount.Discount))
+	}
+	return response
 }
 
 func RemoveFromCart(c *gin.Context) {
@@ -125,15 +304,14 @@ func RemoveFromCart(c *gin.Context) {
 
 	userObjectID, _ := primitive.ObjectIDFromHex(userID.(string))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	ctx := c.Request.Context()
 
-	result, err := cartCollection.DeleteOne(ctx, bson.M{
+	result, err := cartCollection().DeleteOne(ctx, bson.M{
 		"_id":     objectID,
 		"user_id": userObjectID,
 	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove from cart"})
+		respondDBError(c, err, "Failed to remove from cart")
 		return
 	}
 
@@ -142,5 +320,5 @@ func RemoveFromCart(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Item removed from cart"})
+	c.JSON(http.StatusOK, dto.MessageResponse{Message: "Item removed from cart"})
 }
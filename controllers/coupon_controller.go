@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
+	"ecommerce-backend/models"
+	"ecommerce-backend/money"
+	"ecommerce-backend/pricing"
+	"ecommerce-backend/promotions"
+	"ecommerce-backend/tax"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errUnknownCoupon = &promotions.RejectedError{Reason: "this coupon code is not valid"}
+
+func cartCouponCollection() *mongo.Collection {
+	return config.GetCollection("cart_coupons")
+}
+
+// evaluateCoupon loads the promotion matching filter and works out its
+// discount on the cart priced in currency. Promotions that do not apply
+// return a *promotions.RejectedError.
+func evaluateCoupon(ctx context.Context, userID primitive.ObjectID, filter bson.M, items []models.CartItem, currency string, rates money.Rates) (models.Promotion, promotions.Result, error) {
+	var promotion models.Promotion
+	err := promotionCollection().FindOne(ctx, filter).Decode(&promotion)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Promotion{}, promotions.Result{}, errUnknownCoupon
+	}
+	if err != nil {
+		return models.Promotion{}, promotions.Result{}, err
+	}
+
+	used, err := promotionRedemptionCollection().CountDocuments(ctx, bson.M{
+		"promotion_id": promotion.ID,
+		"user_id":      userID,
+	})
+	if err != nil {
+		return models.Promotion{}, promotions.Result{}, err
+	}
+	if err := promotions.CheckAvailable(promotion, time.Now(), int(used)); err != nil {
+		return models.Promotion{}, promotions.Result{}, err
+	}
+
+	converted, err := promotions.InCurrency(promotion, currency, rates)
+	if err != nil {
+		return models.Promotion{}, promotions.Result{}, err
+	}
+
+	prices, err := pricing.UnitPrices(items, currency)
+	if err != nil {
+		return models.Promotion{}, promotions.Result{}, err
+	}
+	lines := make([]promotions.Line, 0, len(items))
+	for i, item := range items {
+		lines = append(lines, promotions.Line{
+			ID:        item.ID.Hex(),
+			ProductID: item.Product.ID,
+			Category:  item.Product.Category,
+			UnitPrice: prices[i],
+			Quantity:  item.Quantity,
+		})
+	}
+	result, err := promotions.Apply(converted, currency, lines)
+	return promotion, result, err
+}
+
+// ApplyCoupon puts a coupon on the cart, replacing any earlier one, and
+// returns the repriced cart. Coupons that do not apply to the cart as it
+// stands are refused with the reason.
+func ApplyCoupon(cfg *config.Config, taxes tax.Calculator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var req dto.ApplyCouponRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		pricer, ok := cartPricerFromQuery(c, cfg, taxes)
+		if !ok {
+			return
+		}
+
+		userObjectID, _ := primitive.ObjectIDFromHex(userID.(string))
+
+		ctx := c.Request.Context()
+
+		items, err := findCartItems(ctx, userObjectID)
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch cart")
+			return
+		}
+
+		promotion, discount, err := evaluateCoupon(ctx, userObjectID, bson.M{"code": normalizeCouponCode(req.Code)}, items, pricer.currency(items), pricer.rates)
+		var rejected *promotions.RejectedError
+		if errors.As(err, &rejected) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": rejected.Reason})
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to apply coupon")
+			return
+		}
+
+		_, err = cartCouponCollection().ReplaceOne(ctx,
+			bson.M{"_id": userObjectID},
+			models.CartCoupon{
+				UserID:      userObjectID,
+				PromotionID: promotion.ID,
+				Code:        promotion.Code,
+				AppliedAt:   time.Now(),
+			},
+			options.Replace().SetUpsert(true),
+		)
+		if err != nil {
+			respondDBError(c, err, "Failed to apply coupon")
+			return
+		}
+
+		cart, err := pricer.price(items, &promotion, discount)
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch cart")
+			return
+		}
+		c.JSON(http.StatusOK, cart.response())
+	}
+}
+
+// RemoveCoupon takes the coupon off the cart and returns the repriced cart.
+func RemoveCoupon(cfg *config.Config, taxes tax.Calculator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		pricer, ok := cartPricerFromQuery(c, cfg, taxes)
+		if !ok {
+			return
+		}
+
+		userObjectID, _ := primitive.ObjectIDFromHex(userID.(string))
+
+		ctx := c.Request.Context()
+
+		result, err := cartCouponCollection().DeleteOne(ctx, bson.M{"_id": userObjectID})
+		if err != nil {
+			respondDBError(c, err, "Failed to remove coupon")
+			return
+		}
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No coupon applied"})
+			return
+		}
+
+		cart, err := pricer.priceCart(ctx, userObjectID)
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch cart")
+			return
+		}
+		c.JSON(http.StatusOK, cart.response())
+	}
+}
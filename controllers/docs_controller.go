@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+	"sync"
+
+	"ecommerce-backend/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+const swaggerUIPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline' https://unpkg.com; " +
+	"style-src 'self' 'unsafe-inline' https://unpkg.com; img-src 'self' data: https:; frame-ancestors 'none'"
+
+// OpenAPISpec serves the OpenAPI document generated from the routes
+// registered on router. It is built on first request, once every route
+// has been registered.
+func OpenAPISpec(router *gin.Engine, registry *openapi.Registry, info openapi.Info) gin.HandlerFunc {
+	var (
+		once sync.Once
+		doc  *openapi.Document
+	)
+	return func(c *gin.Context) {
+		once.Do(func() {
+			doc = registry.Build(router.Routes(), info)
+		})
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+func SwaggerUI(c *gin.Context) {
+	// Swagger UI is loaded from unpkg and bootstrapped by an inline script
+	c.Header("Content-Security-Policy", swaggerUIPolicy)
+	c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.SwaggerUI)
+}
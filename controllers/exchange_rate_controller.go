@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
+	"ecommerce-backend/models"
+	"ecommerce-backend/money"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func exchangeRateCollection() *mongo.Collection {
+	return config.GetCollection("exchange_rates")
+}
+
+func findExchangeRates(ctx context.Context) ([]models.ExchangeRate, error) {
+	cursor, err := exchangeRateCollection().Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rates []models.ExchangeRate
+	if err = cursor.All(ctx, &rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// loadRates returns the exchange rate table for converting display prices.
+func loadRates(ctx context.Context, base string) (money.Rates, error) {
+	rates, err := findExchangeRates(ctx)
+	if err != nil {
+		return money.Rates{}, err
+	}
+	out := money.Rates{Base: base, Rates: make(map[string]float64, len(rates))}
+	for _, rate := range rates {
+		out.Rates[rate.Currency] = rate.Rate
+	}
+	return out, nil
+}
+
+// displayPrice returns the product's price in currency: its price list
+// entry, or the base price converted at the exchange rate. It returns nil
+// when the currency has no rate.
+func displayPrice(product models.Product, currency string, rates money.Rates) *money.Money {
+	if price, ok := product.PriceIn(currency); ok {
+		return &price
+	}
+	if price, ok := rates.Convert(product.Price, currency); ok {
+		return &price
+	}
+	return nil
+}
+
+// GetExchangeRates lists the exchange rates clients can use to show
+// estimated prices.
+func GetExchangeRates(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rates, err := findExchangeRates(c.Request.Context())
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch exchange rates")
+			return
+		}
+		c.JSON(http.StatusOK, dto.NewExchangeRatesResponse(cfg.Currency.Base, rates))
+	}
+}
+
+// SetExchangeRate creates or replaces the rate of a currency.
+func SetExchangeRate(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currency := strings.ToUpper(c.Param("currency"))
+		if !money.IsCurrency(currency) || currency == cfg.Currency.Base {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported currency or the base currency"})
+			return
+		}
+
+		var req dto.ExchangeRateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		rate := models.ExchangeRate{Currency: currency, Rate: req.Rate, UpdatedAt: time.Now()}
+		_, err := exchangeRateCollection().ReplaceOne(c.Request.Context(),
+			bson.M{"_id": currency}, rate, options.Replace().SetUpsert(true))
+		if err != nil {
+			respondDBError(c, err, "Failed to save exchange rate")
+			return
+		}
+
+		c.JSON(http.StatusOK, dto.NewExchangeRate(rate))
+	}
+}
+
+// DeleteExchangeRate removes a currency's rate. Prices are no longer
+// converted into it, though price list entries in it still apply.
+func DeleteExchangeRate(c *gin.Context) {
+	currency := strings.ToUpper(c.Param("currency"))
+
+	result, err := exchangeRateCollection().DeleteOne(c.Request.Context(), bson.M{"_id": currency})
+	if err != nil {
+		respondDBError(c, err, "Failed to delete exchange rate")
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exchange rate not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.MessageResponse{Message: "Exchange rate deleted"})
+}
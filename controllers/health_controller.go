@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
+	"ecommerce-backend/jobs"
+	"ecommerce-backend/migrations"
+
+	"github.com/gin-gonic/gin"
+)
+
+const readinessCheckTimeout = 2 * time.Second
+
+// Healthz reports that the process is alive. It never touches dependencies
+// so a slow database does not get the instance restarted.
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, dto.HealthResponse{Status: "ok"})
+}
+
+// Readyz reports whether the instance can serve traffic: MongoDB answers
+// pings, all migrations are applied and background jobs are running.
+func Readyz(jobManager *jobs.Manager) gin.HandlerFunc {
+	checks := map[string]func(ctx context.Context) error{
+		"mongodb": func(ctx context.Context) error {
+			if config.Client == nil {
+				return errors.New("not connected")
+			}
+			return config.Client.Ping(ctx, nil)
+		},
+		"migrations": func(ctx context.Context) error {
+			if config.DB == nil {
+				return errors.New("not connected")
+			}
+			pending, err := migrations.Pending(ctx, config.DB)
+			if err != nil {
+				return err
+			}
+			if len(pending) > 0 {
+				return fmt.Errorf("%d pending", len(pending))
+			}
+			return nil
+		},
+		"background_jobs": func(ctx context.Context) error {
+			if !jobManager.Running() {
+				return errors.New("not running")
+			}
+			return nil
+		},
+	}
+
+	return func(c *gin.Context) {
+		results := make(map[string]dto.CheckResult, len(checks))
+		ready := true
+
+		for name, check := range checks {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), readinessCheckTimeout)
+			start := time.Now()
+			err := check(ctx)
+			cancel()
+
+			result := dto.CheckResult{
+				Status:    "ok",
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = "fail"
+				result.Error = err.Error()
+				ready = false
+			}
+			results[name] = result
+		}
+
+		status, code := "ok", http.StatusOK
+		if !ready {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+		c.JSON(code, dto.ReadinessResponse{Status: status, Checks: results})
+	}
+}
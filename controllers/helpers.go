@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// respondDBError writes the response for a failed database operation and
+// records err on the context for the request log. Deadlines set by the
+// timeout middleware map to 504, and requests whose client has gone away
+// are aborted without writing a body.
+func respondDBError(c *gin.Context, err error, message string) {
+	_ = c.Error(err)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		c.Abort()
+	case errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
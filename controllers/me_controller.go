@@ -0,0 +1,304 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
+	"ecommerce-backend/logger"
+	"ecommerce-backend/mailer"
+	"ecommerce-backend/middleware"
+	"ecommerce-backend/models"
+	"ecommerce-backend/password"
+	"ecommerce-backend/tokens"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// currentUser loads the authenticated user. On failure it writes the
+// response and returns false.
+func currentUser(c *gin.Context) (models.User, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return models.User{}, false
+	}
+
+	userObjectID, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	var user models.User
+	err := userCollection().FindOne(c.Request.Context(), bson.M{"_id": userObjectID, "deleted_at": bson.M{"$exists": false}}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return models.User{}, false
+	}
+	if err != nil {
+		respondDBError(c, err, "Failed to fetch user")
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// recentLoginWindow is how long after signing in a user without a
+// password may make changes that would otherwise need it.
+const recentLoginWindow = 10 * time.Minute
+
+// checkPassword verifies password against the user's hash, writing a 401
+// response and returning false when it does not match. Accounts created
+// through an identity provider have no password; they confirm by having
+// signed in within recentLoginWindow instead.
+func checkPassword(c *gin.Context, passwords *password.Manager, user models.User, plaintext string) bool {
+	if user.Password == "" {
+		issuedAt, _ := c.Get("issued_at")
+		if at, ok := issuedAt.(time.Time); !ok || time.Since(at) > recentLoginWindow {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign in again with your identity provider to confirm this change"})
+			return false
+		}
+		return true
+	}
+	if ok, _ := passwords.Verify(user.Password, plaintext); !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return false
+	}
+	return true
+}
+
+// hashNewPassword checks plaintext against the password policy and hashes
+// it. On failure it writes the response and returns false.
+func hashNewPassword(c *gin.Context, passwords *password.Manager, plaintext, email string) (string, bool) {
+	var policyErr *password.PolicyError
+	if err := passwords.Validate(plaintext, email); errors.As(err, &policyErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": policyErr.Error()})
+		return "", false
+	}
+
+	hash, err := passwords.Hash(plaintext)
+	if err != nil {
+		_ = c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return "", false
+	}
+	return hash, true
+}
+
+func GetMe(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewUser(user))
+}
+
+// UpdateMe changes the user's name and starts an email change. The new
+// address only replaces the current one after it has been verified.
+func UpdateMe(cfg *config.Config, mail mailer.Mailer, passwords *password.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.UpdateProfileRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, ok := currentUser(c)
+		if !ok {
+			return
+		}
+
+		ctx := c.Request.Context()
+		set := bson.M{"updated_at": time.Now()}
+
+		if req.Name != nil {
+			set["name"] = strings.TrimSpace(*req.Name)
+			user.Name = set["name"].(string)
+		}
+
+		emailChanged := req.Email != nil && !strings.EqualFold(*req.Email, user.Email)
+		if emailChanged {
+			if !checkPassword(c, passwords, user, req.CurrentPassword) {
+				return
+			}
+
+			err := userCollection().FindOne(ctx, bson.M{"email": *req.Email}).Err()
+			if err == nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "Email is already in use"})
+				return
+			}
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				respondDBError(c, err, "Failed to check email")
+				return
+			}
+			set["pending_email"] = *req.Email
+			user.PendingEmail = *req.Email
+		}
+
+		_, err := userCollection().UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": set})
+		if err != nil {
+			respondDBError(c, err, "Failed to update profile")
+			return
+		}
+
+		if emailChanged {
+			sendVerificationEmail(ctx, cfg, mail, user, user.PendingEmail, models.TokenChangeEmail)
+
+			// Let the current address know in case the change was not theirs
+			err := mail.Send(ctx, mailer.Message{
+				To:      user.Email,
+				Subject: "Your email address is being changed",
+				Body: fmt.Sprintf("Hi %s,\n\nA request was made to change your account email to %s. "+
+					"If this was not you, reset your password immediately.\n", user.Name, user.PendingEmail),
+			})
+			if err != nil {
+				logger.FromContext(ctx).Error("sending email change notice failed", "error", err)
+			}
+		}
+
+		user.UpdatedAt = set["updated_at"].(time.Time)
+		c.JSON(http.StatusOK, dto.NewUser(user))
+	}
+}
+
+// ChangePassword replaces the password after checking the current one.
+// Every other session is signed out; the response carries a new token, or
+// sets a new session cookie when the caller used one.
+func ChangePassword(cfg *config.Config, passwords *password.Manager, keys *tokens.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.ChangePasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, ok := currentUser(c)
+		if !ok || !checkPassword(c, passwords, user, req.CurrentPassword) {
+			return
+		}
+
+		hashedPassword, ok := hashNewPassword(c, passwords, req.NewPassword, user.Email)
+		if !ok {
+			return
+		}
+
+		now := time.Now()
+		_, err := userCollection().UpdateOne(c.Request.Context(), bson.M{"_id": user.ID}, bson.M{
+			"$set": bson.M{"password": hashedPassword, "sessions_valid_after": now, "updated_at": now},
+		})
+		if err != nil {
+			respondDBError(c, err, "Failed to change password")
+			return
+		}
+
+		token, err := keys.Issue(user.ID.Hex())
+		if err != nil {
+			_ = c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		// Keep a cookie session signed in with the new token
+		if c.GetString("auth_source") == middleware.AuthSourceCookie {
+			setSessionCookie(c, cfg, token)
+			c.JSON(http.StatusOK, dto.TokenResponse{Message: "Password changed successfully"})
+			return
+		}
+		c.JSON(http.StatusOK, dto.TokenResponse{Message: "Password changed successfully", Token: token})
+	}
+}
+
+// DeleteMe anonymizes the account, removes the user's cart, coupon, tokens and
+// login history, and revokes every session. The user document is kept so
+// references from other collections stay valid.
+func DeleteMe(cfg *config.Config, passwords *password.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.DeleteAccountRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, ok := currentUser(c)
+		if !ok || !checkPassword(c, passwords, user, req.CurrentPassword) {
+			return
+		}
+
+		ctx := c.Request.Context()
+		now := time.Now()
+
+		_, err := userCollection().UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+			"$set": bson.M{
+				"email":                fmt.Sprintf("deleted-%s@deleted.invalid", user.ID.Hex()),
+				"name":                 "Deleted user",
+				"password":             "",
+				"email_verified":       false,
+				"two_factor_enabled":   false,
+				"deleted_at":           now,
+				"sessions_valid_after": now,
+				"updated_at":           now,
+			},
+			"$unset": bson.M{
+				"pending_email": "", "locked_until": "",
+				"totp_secret": "", "pending_totp_secret": "", "totp_last_step": "", "recovery_codes": "",
+				"identities": "",
+			},
+		})
+		if err != nil {
+			respondDBError(c, err, "Failed to delete account")
+			return
+		}
+
+		for _, collection := range []*mongo.Collection{cartCollection(), userTokenCollection(), loginEventCollection()} {
+			if _, err := collection.DeleteMany(ctx, bson.M{"user_id": user.ID}); err != nil {
+				respondDBError(c, err, "Failed to delete account data")
+				return
+			}
+		}
+		if _, err := cartCouponCollection().DeleteOne(ctx, bson.M{"_id": user.ID}); err != nil {
+			respondDBError(c, err, "Failed to delete account data")
+			return
+		}
+
+		clearSessionCookie(c, cfg)
+		c.JSON(http.StatusOK, dto.MessageResponse{Message: "Account deleted"})
+	}
+}
+
+// GetSecurityEvents lists the authenticated user's most recent sign-in
+// attempts, newest first.
+func GetSecurityEvents(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userObjectID, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	ctx := c.Request.Context()
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(securityEventsLimit)
+
+	cursor, err := loginEventCollection().Find(ctx, bson.M{"user_id": userObjectID}, findOptions)
+	if err != nil {
+		respondDBError(c, err, "Failed to fetch security events")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var events []models.LoginEvent
+	if err = cursor.All(ctx, &events); err != nil {
+		respondDBError(c, err, "Failed to decode security events")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewSecurityEventsResponse(events))
+}
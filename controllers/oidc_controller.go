@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
+	"ecommerce-backend/models"
+	"ecommerce-backend/oidc"
+	"ecommerce-backend/tokens"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// oidcLoginTTL is how long a user has to complete sign-in at the provider.
+const oidcLoginTTL = 10 * time.Minute
+
+// oidcStateCookie binds a cookie-mode login to the browser that started
+// it, so nobody can send a victim a callback URL that signs them in to
+// another account.
+const oidcStateCookie = "oidc_state"
+
+var errUnverifiedAccount = errors.New("an unverified account uses this email")
+
+func oidcLoginCollection() *mongo.Collection {
+	return config.GetCollection("oidc_logins")
+}
+
+// ListOIDCProviders lists the configured identity providers with the URL
+// that starts a login with each.
+func ListOIDCProviders(providers *oidc.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		base := strings.TrimSuffix(c.FullPath(), "/oidc")
+		response := dto.OIDCProvidersResponse{Providers: []dto.OIDCProvider{}}
+		for _, name := range providers.Names() {
+			response.Providers = append(response.Providers, dto.OIDCProvider{
+				Name:     name,
+				LoginURL: base + "/oidc/" + name + "/login",
+			})
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// StartOIDCLogin redirects the browser to the provider. The state, nonce
+// and PKCE verifier are kept server-side until the callback.
+func StartOIDCLogin(cfg *config.Config, providers *oidc.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provider, ok := providers.Get(c.Param("provider"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+			return
+		}
+
+		var query dto.OIDCLoginQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		mode := query.Mode
+		if mode == "" {
+			mode = dto.LoginModeCookie
+		}
+
+		var values [3]string
+		for i := range values {
+			value, err := oidc.RandomString()
+			if err != nil {
+				_ = c.Error(err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+				return
+			}
+			values[i] = value
+		}
+		state, nonce, verifier := values[0], values[1], values[2]
+
+		ctx := c.Request.Context()
+		_, err := oidcLoginCollection().InsertOne(ctx, models.OIDCLogin{
+			StateHash:    hashToken(state),
+			Provider:     provider.Name(),
+			Nonce:        nonce,
+			CodeVerifier: verifier,
+			Mode:         mode,
+			ExpiresAt:    time.Now().Add(oidcLoginTTL),
+		})
+		if err != nil {
+			respondDBError(c, err, "Failed to start login")
+			return
+		}
+
+		authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+		if err != nil {
+			_ = c.Error(err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+			return
+		}
+
+		if mode == dto.LoginModeCookie {
+			// Lax, not strict: the provider's redirect back is cross-site
+			c.SetSameSite(http.SameSiteLaxMode)
+			c.SetCookie(oidcStateCookie, state, int(oidcLoginTTL.Seconds()), "/", "", cfg.Server.IsHTTPS(), true)
+		}
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// OIDCCallback completes a social login: it redeems the code, verifies the
+// ID token and links or creates the account. In token mode it responds
+// with the usual JWT (or a two-factor challenge); in cookie mode it sets
+// the session cookie and redirects to the storefront, or to the sign-in
+// page to enter the two-factor code.
+func OIDCCallback(cfg *config.Config, providers *oidc.Registry, keys *tokens.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provider, ok := providers.Get(c.Param("provider"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+			return
+		}
+
+		var query dto.OIDCCallbackQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if query.Error != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign-in failed at the identity provider: " + query.Error})
+			return
+		}
+		if query.Code == "" || query.State == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing code or state"})
+			return
+		}
+
+		ctx := c.Request.Context()
+
+		// Each state is used once
+		var login models.OIDCLogin
+		err := oidcLoginCollection().FindOneAndDelete(ctx, bson.M{
+			"_id":        hashToken(query.State),
+			"provider":   provider.Name(),
+			"expires_at": bson.M{"$gt": time.Now()},
+		}).Decode(&login)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to complete login")
+			return
+		}
+		if login.Mode == dto.LoginModeCookie {
+			cookie, _ := c.Cookie(oidcStateCookie)
+			c.SetSameSite(http.SameSiteLaxMode)
+			c.SetCookie(oidcStateCookie, "", -1, "/", "", cfg.Server.IsHTTPS(), true)
+			if subtle.ConstantTimeCompare([]byte(cookie), []byte(query.State)) != 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Login was started in another browser"})
+				return
+			}
+		}
+
+		claims, err := provider.Exchange(ctx, query.Code, login.CodeVerifier, login.Nonce)
+		if err != nil {
+			_ = c.Error(err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign-in with the identity provider failed"})
+			return
+		}
+		if claims.Email == "" || !claims.EmailVerified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "The identity provider did not confirm a verified email address"})
+			return
+		}
+
+		user, err := linkOIDCIdentity(ctx, provider.Name(), claims)
+		if errors.Is(err, errUnverifiedAccount) || mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "An unverified account already uses this email; verify it or reset its password first"})
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to sign in")
+			return
+		}
+
+		if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+			failedLogin(c, user, models.LoginReasonLocked, user.LockedUntil)
+			return
+		}
+		if login.Mode != dto.LoginModeCookie {
+			if user.TwoFactorEnabled {
+				issueLoginChallenge(c, cfg, user)
+				return
+			}
+			completeLogin(c, cfg, keys, user, dto.LoginModeToken)
+			return
+		}
+
+		if user.TwoFactorEnabled {
+			challenge, err := issueUserToken(ctx, user.ID, models.TokenLoginChallenge, cfg.TwoFactor.ChallengeTTL)
+			if err != nil {
+				respondDBError(c, err, "Failed to start two-factor login")
+				return
+			}
+			// In the fragment, which browsers neither send to servers nor
+			// put in Referer headers
+			c.Redirect(http.StatusFound, "/login#"+url.Values{"challenge": {challenge}}.Encode())
+			return
+		}
+		token, ok := startSession(c, keys, user)
+		if !ok {
+			return
+		}
+		setSessionCookie(c, cfg, token)
+		c.Redirect(http.StatusFound, "/products")
+	}
+}
+
+// linkOIDCIdentity returns the account for an external identity. Unknown
+// identities are linked to the account with the same email when that
+// account's email is verified; otherwise a new account is created. Linking
+// to an unverified account is refused, since whoever registered it may not
+// own the address.
+func linkOIDCIdentity(ctx context.Context, provider string, claims oidc.Claims) (models.User, error) {
+	active := bson.M{"$exists": false}
+
+	var user models.User
+	err := userCollection().FindOne(ctx, bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": claims.Subject}},
+		"deleted_at": active,
+	}).Decode(&user)
+	if err == nil || !errors.Is(err, mongo.ErrNoDocuments) {
+		return user, err
+	}
+
+	now := time.Now()
+	identity := models.ExternalIdentity{Provider: provider, Subject: claims.Subject, LinkedAt: now}
+
+	err = userCollection().FindOne(ctx, bson.M{"email": claims.Email, "deleted_at": active}).Decode(&user)
+	if err == nil {
+		if !user.EmailVerified {
+			return models.User{}, errUnverifiedAccount
+		}
+		_, err = userCollection().UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+			"$push": bson.M{"identities": identity},
+			"$set":  bson.M{"updated_at": now},
+		})
+		return user, err
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return models.User{}, err
+	}
+
+	name := claims.Name
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+	user = models.User{
+		ID:            primitive.NewObjectID(),
+		Email:         claims.Email,
+		Name:          name,
+		EmailVerified: true,
+		Role:          models.RoleCustomer,
+		Identities:    []models.ExternalIdentity{identity},
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	_, err = userCollection().InsertOne(ctx, user)
+	return user, err
+}
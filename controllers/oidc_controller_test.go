@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
+	"ecommerce-backend/models"
+	"ecommerce-backend/oidc"
+	"ecommerce-backend/oidc/oidctest"
+	"ecommerce-backend/tokens"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// oidcTest runs the social login endpoints against a mock provider and a
+// scratch database.
+type oidcTest struct {
+	t      *testing.T
+	mock   *oidctest.Provider
+	router *gin.Engine
+	client *http.Client
+}
+
+// newOIDCTest needs a MongoDB server at MONGODB_TEST_URI; each test gets
+// its own database, dropped afterwards.
+func newOIDCTest(t *testing.T) *oidcTest {
+	t.Helper()
+
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+	name := fmt.Sprintf("gommerce_test_%d", time.Now().UnixNano())
+	if err := config.ConnectDB(config.DatabaseConfig{URI: uri, Name: name}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = config.DB.Drop(context.Background())
+		_ = config.DisconnectDB(context.Background())
+	})
+
+	mock, server, err := oidctest.NewServer("gommerce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	cfg := config.Default()
+	cfg.Server.PublicURL = "http://shop.test"
+	cfg.JWT.Secret = "oidc-test-secret-that-is-long-enough"
+	cfg.OIDC.Providers = []config.OIDCProviderConfig{{Name: "mock", Issuer: mock.Issuer, ClientID: "gommerce"}}
+
+	keys, err := tokens.NewKeyring(cfg.JWT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	providers := oidc.NewRegistry(cfg.OIDC, cfg.Server.PublicURL)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/auth/oidc/:provider/login", StartOIDCLogin(cfg, providers))
+	router.GET("/api/auth/oidc/:provider/callback", OIDCCallback(cfg, providers, keys))
+
+	return &oidcTest{
+		t:      t,
+		mock:   mock,
+		router: router,
+		client: &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}},
+	}
+}
+
+func (o *oidcTest) serve(target string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	o.router.ServeHTTP(w, req)
+	return w
+}
+
+// login starts a login in mode, signs in at the provider and returns the
+// callback URL the provider redirects to and the cookies set at the start.
+func (o *oidcTest) login(mode string) (string, []*http.Cookie) {
+	o.t.Helper()
+
+	start := o.serve("/api/auth/oidc/mock/login?mode="+mode, nil)
+	if start.Code != http.StatusFound {
+		o.t.Fatalf("login: status %d: %s", start.Code, start.Body)
+	}
+
+	resp, err := o.client.Get(start.Header().Get("Location"))
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	return callback.RequestURI(), start.Result().Cookies()
+}
+
+func TestOIDCCallbackTokenMode(t *testing.T) {
+	o := newOIDCTest(t)
+	o.mock.SetUser(oidctest.User{Subject: "jane-1", Email: "jane@example.com", EmailVerified: true, Name: "Jane"})
+
+	callback, _ := o.login(dto.LoginModeToken)
+	w := o.serve(callback, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("callback: status %d: %s", w.Code, w.Body)
+	}
+	var response dto.AuthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Token == "" || response.User.Email != "jane@example.com" {
+		t.Fatalf("response = %+v, want a token for jane@example.com", response)
+	}
+
+	// The state is used up
+	if w := o.serve(callback, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("replayed callback: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	// Signing in again finds the linked account instead of creating one
+	callback, _ = o.login(dto.LoginModeToken)
+	if w := o.serve(callback, nil); w.Code != http.StatusOK {
+		t.Fatalf("second callback: status %d: %s", w.Code, w.Body)
+	}
+	count, err := userCollection().CountDocuments(context.Background(), bson.M{"email": "jane@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("%d accounts for jane@example.com, want 1", count)
+	}
+}
+
+func TestOIDCCallbackRefusals(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     oidctest.User
+		existing *models.User
+		want     int
+	}{
+		{
+			name: "unverified email at the provider",
+			user: oidctest.User{Subject: "joe-1", Email: "joe@example.com"},
+			want: http.StatusForbidden,
+		},
+		{
+			name:     "unverified account with the same email",
+			user:     oidctest.User{Subject: "joe-1", Email: "joe@example.com", EmailVerified: true},
+			existing: &models.User{Email: "joe@example.com", Name: "Joe", Password: "x", Role: models.RoleCustomer},
+			want:     http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOIDCTest(t)
+			o.mock.SetUser(tt.user)
+			if tt.existing != nil {
+				tt.existing.ID = primitive.NewObjectID()
+				if _, err := userCollection().InsertOne(context.Background(), tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			callback, _ := o.login(dto.LoginModeToken)
+			if w := o.serve(callback, nil); w.Code != tt.want {
+				t.Fatalf("callback: status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.existing != nil {
+				var user models.User
+				if err := userCollection().FindOne(context.Background(), bson.M{"_id": tt.existing.ID}).Decode(&user); err != nil {
+					t.Fatal(err)
+				}
+				if len(user.Identities) != 0 {
+					t.Errorf("identity linked to the unverified account: %+v", user.Identities)
+				}
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackCookieMode(t *testing.T) {
+	o := newOIDCTest(t)
+	o.mock.SetUser(oidctest.User{Subject: "jane-1", Email: "jane@example.com", EmailVerified: true})
+
+	// A callback opened in a browser that did not start the login
+	callback, _ := o.login(dto.LoginModeCookie)
+	if w := o.serve(callback, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("callback without state cookie: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	callback, cookies := o.login(dto.LoginModeCookie)
+	w := o.serve(callback, cookies)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/products" {
+		t.Fatalf("callback: status %d, location %q: %s", w.Code, w.Header().Get("Location"), w.Body)
+	}
+	session := false
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == config.Default().Session.CookieName && cookie.Value != "" && cookie.HttpOnly {
+			session = true
+		}
+	}
+	if !session {
+		t.Error("callback set no session cookie")
+	}
+}
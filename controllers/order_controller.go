@@ -0,0 +1,424 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
+	"ecommerce-backend/logger"
+	"ecommerce-backend/metrics"
+	"ecommerce-backend/models"
+	"ecommerce-backend/promotions"
+	"ecommerce-backend/shipping"
+	"ecommerce-backend/tax"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ordersLimit caps the order history listing.
+const ordersLimit = 100
+
+var errOutOfStock = errors.New("not enough stock")
+
+func orderCollection() *mongo.Collection {
+	return config.GetCollection("orders")
+}
+
+// Checkout places an order for the cart, priced and taxed for the shipping
+// address in the currency asked for, and shipped by the chosen method. It
+// reserves stock, redeems the coupon and empties the cart.
+func Checkout(cfg *config.Config, taxes tax.Calculator, quoter shipping.Quoter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var req dto.CheckoutRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userObjectID, _ := primitive.ObjectIDFromHex(userID.(string))
+		address := models.Address(req.ShippingAddress)
+
+		ctx := c.Request.Context()
+
+		pricer, err := newCartPricer(ctx, cfg, taxes, tax.Address{Country: address.Country, Region: address.Region}, req.Currency)
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch exchange rates")
+			return
+		}
+
+		cart, err := pricer.priceCart(ctx, userObjectID)
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch cart")
+			return
+		}
+		if len(cart.items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
+			return
+		}
+		if cart.coupon != nil && !cart.coupon.Valid {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": cart.coupon.Message})
+			return
+		}
+
+		method, err := chooseShipping(cfg, quoter, address.Country, cart, req.ShippingMethod)
+		var rejected *shippingError
+		if errors.As(err, &rejected) {
+			c.JSON(rejected.status, gin.H{"error": rejected.message})
+			return
+		}
+
+		order := newOrder(userObjectID, address, cart, method)
+
+		if name, err := reserveStock(ctx, order.Lines); errors.Is(err, errOutOfStock) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Not enough stock for %s", name)})
+			return
+		} else if err != nil {
+			respondDBError(c, err, "Failed to place order")
+			return
+		}
+
+		if cart.promotion != nil {
+			err := claimPromotion(ctx, *cart.promotion, userObjectID)
+			var rejected *promotions.RejectedError
+			if errors.As(err, &rejected) {
+				releaseStock(ctx, order.Lines)
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": rejected.Reason})
+				return
+			}
+			if err != nil {
+				releaseStock(ctx, order.Lines)
+				respondDBError(c, err, "Failed to place order")
+				return
+			}
+		}
+
+		if _, err := orderCollection().InsertOne(ctx, order); err != nil {
+			releaseStock(ctx, order.Lines)
+			if cart.promotion != nil {
+				unclaimPromotion(ctx, *cart.promotion, userObjectID)
+			}
+			respondDBError(c, err, "Failed to place order")
+			return
+		}
+
+		// The order stands from here on; clean-up failures are logged
+		log := logger.FromContext(ctx)
+		if order.Promotion != nil {
+			_, err := promotionRedemptionCollection().InsertOne(ctx, models.PromotionRedemption{
+				PromotionID: order.Promotion.PromotionID,
+				UserID:      userObjectID,
+				OrderID:     order.ID,
+				CreatedAt:   order.CreatedAt,
+			})
+			if err != nil {
+				log.Error("recording promotion redemption failed", "order_id", order.ID.Hex(), "error", err)
+			}
+		}
+		if _, err := cartCollection().DeleteMany(ctx, bson.M{"user_id": userObjectID}); err != nil {
+			log.Error("emptying cart after checkout failed", "order_id", order.ID.Hex(), "error", err)
+		}
+		if _, err := cartCouponCollection().DeleteOne(ctx, bson.M{"_id": userObjectID}); err != nil {
+			log.Error("removing coupon after checkout failed", "order_id", order.ID.Hex(), "error", err)
+		}
+		metrics.OrdersPlaced.Inc()
+
+		c.JSON(http.StatusCreated, dto.NewOrder(order))
+	}
+}
+
+// shippingError is why the order can't be shipped as asked.
+type shippingError struct {
+	status  int
+	message string
+}
+
+func (e *shippingError) Error() string {
+	return e.message
+}
+
+// chooseShipping finds the shipping option with code among those quoted
+// for the cart. Without configured shipping zones orders are not charged
+// for shipping and the option is nil.
+func chooseShipping(cfg *config.Config, quoter shipping.Quoter, country string, cart pricedCart, code string) (*shipping.Option, error) {
+	if len(cfg.Shipping.Zones) == 0 {
+		return nil, nil
+	}
+
+	options := quoter.Quote(country, shippingParcel(cart))
+	if len(options) == 0 {
+		return nil, &shippingError{http.StatusUnprocessableEntity, fmt.Sprintf("We can't ship this order to %s", country)}
+	}
+	if code == "" {
+		return nil, &shippingError{http.StatusBadRequest, "shipping_method is required"}
+	}
+	for _, option := range options {
+		if option.Code == code {
+			return &option, nil
+		}
+	}
+	return nil, &shippingError{http.StatusUnprocessableEntity, fmt.Sprintf("Shipping method %q is not available for this order", code)}
+}
+
+// newOrder copies the priced cart into an order shipped by method, which
+// may be nil.
+func newOrder(userID primitive.ObjectID, address models.Address, cart pricedCart, method *shipping.Option) models.Order {
+	now := time.Now()
+	order := models.Order{
+		ID:              primitive.NewObjectID(),
+		UserID:          userID,
+		Status:          models.OrderStatusPlaced,
+		ShippingAddress: address,
+		Subtotal:        cart.quote.Subtotal,
+		Discount:        cart.quote.Discount,
+		Tax:             cart.quote.Tax,
+		TaxIncluded:     cart.quote.TaxIncluded,
+		Total:           cart.quote.Total,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	for i, item := range cart.items {
+		line := cart.quote.Lines[i]
+		order.Lines = append(order.Lines, models.OrderLine{
+			ProductID: item.Product.ID,
+			Name:      item.Product.Name,
+			TaxClass:  item.Product.TaxClass,
+			UnitPrice: line.UnitPrice,
+			Quantity:  item.Quantity,
+			Subtotal:  line.Subtotal,
+			Discount:  line.Discount,
+			Tax:       line.Tax,
+			Total:     line.Total,
+		})
+	}
+	order.Taxes = []models.OrderTax{}
+	for _, amount := range cart.quote.Taxes {
+		order.Taxes = append(order.Taxes, models.OrderTax{Name: amount.Name, Percent: amount.Percent, Amount: amount.Amount})
+	}
+	if method != nil {
+		order.Shipping = &models.OrderShipping{Code: method.Code, Name: method.Name, Cost: method.Cost}
+		order.Total = order.Total.Add(method.Cost)
+	}
+	if p := cart.promotion; p != nil {
+		order.Promotion = &models.OrderPromotion{
+			PromotionID:  p.ID,
+			Code:         p.Code,
+			Description:  p.Description,
+			Discount:     cart.discount.Discount,
+			FreeShipping: cart.discount.FreeShipping,
+		}
+	}
+	return order
+}
+
+// reserveStock takes each line's quantity off its product's stock. When a
+// product runs short, the lines already reserved are put back and its name
+// is returned with errOutOfStock.
+func reserveStock(ctx context.Context, lines []models.OrderLine) (string, error) {
+	for i, line := range lines {
+		result, err := productCollection().UpdateOne(ctx,
+			bson.M{"_id": line.ProductID, "stock": bson.M{"$gte": line.Quantity}},
+			bson.M{"$inc": bson.M{"stock": -line.Quantity}, "$set": bson.M{"updated_at": time.Now()}},
+		)
+		if err == nil && result.MatchedCount == 0 {
+			err = errOutOfStock
+		}
+		if err != nil {
+			releaseStock(ctx, lines[:i])
+			return line.Name, err
+		}
+	}
+	return "", nil
+}
+
+// releaseStock puts reserved stock back after a failed checkout.
+func releaseStock(ctx context.Context, lines []models.OrderLine) {
+	for _, line := range lines {
+		_, err := productCollection().UpdateOne(context.WithoutCancel(ctx),
+			bson.M{"_id": line.ProductID},
+			bson.M{"$inc": bson.M{"stock": line.Quantity}},
+		)
+		if err != nil {
+			logger.FromContext(ctx).Error("releasing stock failed", "product_id", line.ProductID.Hex(), "error", err)
+		}
+	}
+}
+
+// claimPromotion counts a use of the promotion by the user unless that
+// would exceed its global or per-user limit, returning a
+// *promotions.RejectedError when it would. Each check and increment is one
+// atomic update, so concurrent checkouts can't overshoot either limit.
+func claimPromotion(ctx context.Context, p models.Promotion, userID primitive.ObjectID) error {
+	key := models.PromotionUsageKey{PromotionID: p.ID, UserID: userID}
+	if p.MaxUsesPerUser > 0 {
+		// When the user is at the limit the filter misses their counter and
+		// the upsert collides with it on _id
+		_, err := promotionUsageCollection().UpdateOne(ctx,
+			bson.M{"_id": key, "count": bson.M{"$lt": p.MaxUsesPerUser}},
+			bson.M{"$inc": bson.M{"count": 1}},
+			options.Update().SetUpsert(true),
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			return promotions.ErrUsedByUser
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := promotionCollection().UpdateOne(ctx,
+		bson.M{
+			"_id": p.ID,
+			"$expr": bson.M{"$or": bson.A{
+				bson.M{"$lte": bson.A{"$max_uses", 0}},
+				bson.M{"$lt": bson.A{"$times_used", "$max_uses"}},
+			}},
+		},
+		bson.M{"$inc": bson.M{"times_used": 1}},
+	)
+	if err == nil && result.MatchedCount == 0 {
+		err = promotions.ErrUsedUp
+	}
+	if err != nil && p.MaxUsesPerUser > 0 {
+		unclaimUserPromotion(ctx, key)
+	}
+	return err
+}
+
+// unclaimPromotion gives back a use claimed for an order that failed.
+func unclaimPromotion(ctx context.Context, p models.Promotion, userID primitive.ObjectID) {
+	_, err := promotionCollection().UpdateOne(context.WithoutCancel(ctx),
+		bson.M{"_id": p.ID}, bson.M{"$inc": bson.M{"times_used": -1}})
+	if err != nil {
+		logger.FromContext(ctx).Error("releasing promotion use failed", "promotion_id", p.ID.Hex(), "error", err)
+	}
+	if p.MaxUsesPerUser > 0 {
+		unclaimUserPromotion(ctx, models.PromotionUsageKey{PromotionID: p.ID, UserID: userID})
+	}
+}
+
+func unclaimUserPromotion(ctx context.Context, key models.PromotionUsageKey) {
+	_, err := promotionUsageCollection().UpdateOne(context.WithoutCancel(ctx),
+		bson.M{"_id": key}, bson.M{"$inc": bson.M{"count": -1}})
+	if err != nil {
+		logger.FromContext(ctx).Error("releasing promotion use failed", "promotion_id", key.PromotionID.Hex(), "error", err)
+	}
+}
+
+// ListOrders lists the user's most recent orders, newest first.
+func ListOrders(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userObjectID, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	ctx := c.Request.Context()
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(ordersLimit)
+
+	cursor, err := orderCollection().Find(ctx, bson.M{"user_id": userObjectID}, findOptions)
+	if err != nil {
+		respondDBError(c, err, "Failed to fetch orders")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var orders []models.Order
+	if err = cursor.All(ctx, &orders); err != nil {
+		respondDBError(c, err, "Failed to decode orders")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewOrdersResponse(orders))
+}
+
+// ListAllOrders lists every customer's orders, newest first, optionally
+// only those with the given status. It is for staff and for integrations
+// with an orders:read API key.
+func ListAllOrders(c *gin.Context) {
+	var query dto.AdminOrdersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := bson.M{}
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+
+	ctx := c.Request.Context()
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((query.Page - 1) * query.Limit)).
+		SetLimit(int64(query.Limit))
+
+	cursor, err := orderCollection().Find(ctx, filter, findOptions)
+	if err != nil {
+		respondDBError(c, err, "Failed to fetch orders")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var orders []models.Order
+	if err = cursor.All(ctx, &orders); err != nil {
+		respondDBError(c, err, "Failed to decode orders")
+		return
+	}
+
+	total, err := orderCollection().CountDocuments(ctx, filter)
+	if err != nil {
+		respondDBError(c, err, "Failed to count orders")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewAdminOrdersResponse(orders, query.Page, query.Limit, total))
+}
+
+func GetOrder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	userObjectID, _ := primitive.ObjectIDFromHex(userID.(string))
+
+	var order models.Order
+	err = orderCollection().FindOne(c.Request.Context(), bson.M{"_id": objectID, "user_id": userObjectID}).Decode(&order)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		respondDBError(c, err, "Failed to fetch order")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewOrder(order))
+}
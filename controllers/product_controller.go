@@ -2,31 +2,33 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
 	"ecommerce-backend/models"
+	"ecommerce-backend/money"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var productCollection = config.GetCollection("products")
-
-func GetProducts(c *gin.Context) {
-	var query models.ProductQuery
-	if err := c.ShouldBindQuery(&query); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+func productCollection() *mongo.Collection {
+	return config.GetCollection("products")
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// legacyListLimit caps the unpaginated v1 product listing.
+const legacyListLimit = 500
 
-	// Build filter
+func productFilter(query dto.ProductQuery) bson.M {
 	filter := bson.M{}
 	if query.Search != "" {
 		filter["name"] = bson.M{"$regex": query.Search, "$options": "i"}
@@ -34,130 +36,283 @@ func GetProducts(c *gin.Context) {
 	if query.Category != "" {
 		filter["category"] = query.Category
 	}
+	return filter
+}
 
-	// Calculate skip value for pagination
-	skip := (query.Page - 1) * query.Limit
-
-	// Find options
+func findProducts(ctx context.Context, filter bson.M, limit, skip int) ([]models.Product, error) {
 	findOptions := options.Find()
-	findOptions.SetLimit(int64(query.Limit))
+	findOptions.SetLimit(int64(limit))
 	findOptions.SetSkip(int64(skip))
 	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}})
 
-	cursor, err := productCollection.Find(ctx, filter, findOptions)
+	cursor, err := productCollection().Find(ctx, filter, findOptions)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
-		return
+		return nil, err
 	}
 	defer cursor.Close(ctx)
 
 	var products []models.Product
 	if err = cursor.All(ctx, &products); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode products"})
-		return
+		return nil, err
 	}
-
-	// Get total count for pagination
-	total, _ := productCollection.CountDocuments(ctx, filter)
-
-	c.JSON(http.StatusOK, gin.H{
-		"products": products,
-		"pagination": gin.H{
-			"page":       query.Page,
-			"limit":      query.Limit,
-			"total":      total,
-			"totalPages": (total + int64(query.Limit) - 1) / int64(query.Limit),
-		},
-	})
+	return products, nil
 }
 
-func GetProduct(c *gin.Context) {
-	id := c.Param("id")
-	objectID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	var product models.Product
-	err = productCollection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&product)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
-		return
+// GetProducts lists products, with display prices when a currency is
+// asked for.
+func GetProducts(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query dto.ProductQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx := c.Request.Context()
+		filter := productFilter(query)
+
+		// Calculate skip value for pagination
+		skip := (query.Page - 1) * query.Limit
+
+		products, err := findProducts(ctx, filter, query.Limit, skip)
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch products")
+			return
+		}
+
+		// Get total count for pagination
+		total, err := productCollection().CountDocuments(ctx, filter)
+		if err != nil {
+			respondDBError(c, err, "Failed to count products")
+			return
+		}
+
+		response := dto.NewProductListV2(products, query.Page, query.Limit, total)
+		if query.Currency != "" {
+			rates, err := loadRates(ctx, cfg.Currency.Base)
+			if err != nil {
+				respondDBError(c, err, "Failed to fetch exchange rates")
+				return
+			}
+			currency := strings.ToUpper(query.Currency)
+			for i, product := range products {
+				response.Products[i].DisplayPrice = displayPrice(product, currency, rates)
+			}
+		}
+		c.JSON(http.StatusOK, response)
 	}
-
-	c.JSON(http.StatusOK, product)
 }
 
-func CreateProduct(c *gin.Context) {
-	var product models.Product
-	if err := c.ShouldBindJSON(&product); err != nil {
+// GetProductsV1 returns the legacy storefront listing: a bare array of
+// products without pagination.
+func GetProductsV1(c *gin.Context) {
+	var query dto.ProductQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	product.ID = primitive.NewObjectID()
-	product.CreatedAt = time.Now()
-	product.UpdatedAt = time.Now()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	_, err := productCollection.InsertOne(ctx, product)
+	products, err := findProducts(c.Request.Context(), productFilter(query), legacyListLimit, 0)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+		respondDBError(c, err, "Failed to fetch products")
 		return
 	}
 
-	c.JSON(http.StatusCreated, product)
+	c.JSON(http.StatusOK, dto.NewProductListV1(products))
 }
 
-func UpdateProduct(c *gin.Context) {
-	id := c.Param("id")
-	objectID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
+// GetProductV1 looks a product up by its integer legacy id.
+func GetProductV1(c *gin.Context) {
+	legacyID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || legacyID <= 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
 		return
 	}
 
-	var updateData models.Product
-	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var product models.Product
+	err = productCollection().FindOne(c.Request.Context(), bson.M{"legacy_id": legacyID}).Decode(&product)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if err != nil {
+		respondDBError(c, err, "Failed to fetch product")
 		return
 	}
 
-	updateData.UpdatedAt = time.Now()
+	c.JSON(http.StatusOK, dto.NewProductV1(product))
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// GetProduct returns a product, with a display price when a currency is
+// asked for.
+func GetProduct(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		var query dto.CurrencyQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx := c.Request.Context()
+
+		var product models.Product
+		err = productCollection().FindOne(ctx, bson.M{"_id": objectID}).Decode(&product)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch product")
+			return
+		}
+
+		response := dto.NewProductV2(product)
+		if query.Currency != "" {
+			rates, err := loadRates(ctx, cfg.Currency.Base)
+			if err != nil {
+				respondDBError(c, err, "Failed to fetch exchange rates")
+				return
+			}
+			response.DisplayPrice = displayPrice(product, strings.ToUpper(query.Currency), rates)
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
 
-	update := bson.M{
-		"$set": bson.M{
-			"name":        updateData.Name,
-			"price":       updateData.Price,
-			"image":       updateData.Image,
-			"description": updateData.Description,
-			"category":    updateData.Category,
-			"stock":       updateData.Stock,
-			"updated_at":  updateData.UpdatedAt,
-		},
+// checkProductPrices checks that the required price is in the base
+// currency, which it defaults to, and that the price list has at most one price per other
+// supported currency.
+func checkProductPrices(req *dto.ProductRequest, base string) error {
+	if req.Price.Currency == "" {
+		req.Price.Currency = base
+	}
+	if req.Price.Currency != base {
+		return fmt.Errorf("price must be in the base currency %s; list other currencies under prices", base)
+	}
+	if req.Price.Amount < 0 {
+		return errors.New("price must not be negative")
 	}
 
-	result, err := productCollection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
-		return
+	seen := map[string]bool{}
+	for _, price := range req.Prices {
+		switch {
+		case !money.IsCurrency(price.Currency) || price.Currency == base:
+			return fmt.Errorf("prices: %q is not a supported currency other than %s", price.Currency, base)
+		case seen[price.Currency]:
+			return fmt.Errorf("prices: %s is listed twice", price.Currency)
+		case price.Amount < 0:
+			return errors.New("prices must not be negative")
+		}
+		seen[price.Currency] = true
 	}
+	return nil
+}
 
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
-		return
+func CreateProduct(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.ProductRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkProductPrices(&req, cfg.Currency.Base); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx := c.Request.Context()
+
+		legacyID, err := config.NextSequence(ctx, "products")
+		if err != nil {
+			respondDBError(c, err, "Failed to create product")
+			return
+		}
+
+		now := time.Now()
+		product := models.Product{
+			ID:          primitive.NewObjectID(),
+			LegacyID:    legacyID,
+			Name:        req.Name,
+			Price:       *req.Price,
+			Prices:      req.Prices,
+			Image:       req.Image,
+			Description: req.Description,
+			Category:    req.Category,
+			TaxClass:    req.TaxClass,
+			Stock:       *req.Stock,
+			Weight:      req.Weight,
+			Dimensions:  (*models.Dimensions)(req.Dimensions),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+
+		_, err = productCollection().InsertOne(ctx, product)
+		if err != nil {
+			respondDBError(c, err, "Failed to create product")
+			return
+		}
+
+		c.JSON(http.StatusCreated, dto.NewProductV2(product))
 	}
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Product updated successfully"})
+func UpdateProduct(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		var req dto.ProductRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkProductPrices(&req, cfg.Currency.Base); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx := c.Request.Context()
+
+		update := bson.M{
+			"$set": bson.M{
+				"name":        req.Name,
+				"price":       *req.Price,
+				"prices":      req.Prices,
+				"image":       req.Image,
+				"description": req.Description,
+				"category":    req.Category,
+				"tax_class":   req.TaxClass,
+				"stock":       *req.Stock,
+				"weight":      req.Weight,
+				"dimensions":  (*models.Dimensions)(req.Dimensions),
+				"updated_at":  time.Now(),
+			},
+		}
+
+		result, err := productCollection().UpdateOne(ctx, bson.M{"_id": objectID}, update)
+		if err != nil {
+			respondDBError(c, err, "Failed to update product")
+			return
+		}
+
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, dto.MessageResponse{Message: "Product updated successfully"})
+	}
 }
 
 func DeleteProduct(c *gin.Context) {
@@ -168,12 +323,11 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	ctx := c.Request.Context()
 
-	result, err := productCollection.DeleteOne(ctx, bson.M{"_id": objectID})
+	result, err := productCollection().DeleteOne(ctx, bson.M{"_id": objectID})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product"})
+		respondDBError(c, err, "Failed to delete product")
 		return
 	}
 
@@ -182,5 +336,5 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
+	c.JSON(http.StatusOK, dto.MessageResponse{Message: "Product deleted successfully"})
 }
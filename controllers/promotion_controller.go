@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
+	"ecommerce-backend/models"
+	"ecommerce-backend/money"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func promotionCollection() *mongo.Collection {
+	return config.GetCollection("promotions")
+}
+
+func promotionUsageCollection() *mongo.Collection {
+	return config.GetCollection("promotion_usage")
+}
+
+func promotionRedemptionCollection() *mongo.Collection {
+	return config.GetCollection("promotion_redemptions")
+}
+
+// normalizeCouponCode makes codes case-insensitive; they are stored
+// upper-case.
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// promotionFromRequest checks the fields that depend on the promotion type
+// and converts the request into a promotion.
+func promotionFromRequest(req dto.PromotionRequest) (models.Promotion, error) {
+	promotion := models.Promotion{
+		Code:           normalizeCouponCode(req.Code),
+		Description:    req.Description,
+		Type:           req.Type,
+		MinOrderValue:  req.MinOrderValue,
+		Categories:     req.Categories,
+		MaxUses:        req.MaxUses,
+		MaxUsesPerUser: req.MaxUsesPerUser,
+		StartsAt:       req.StartsAt,
+		EndsAt:         req.EndsAt,
+		Active:         req.Active,
+	}
+	if promotion.Code == "" {
+		return models.Promotion{}, errors.New("Code must not be blank")
+	}
+
+	switch req.Type {
+	case models.PromotionPercentage:
+		if req.Value <= 0 || req.Value > 100 {
+			return models.Promotion{}, errors.New("Percentage promotions need a value between 0 and 100")
+		}
+		promotion.Value = req.Value
+	case models.PromotionFixedAmount:
+		if req.Amount == nil || req.Amount.Amount <= 0 || !money.IsCurrency(req.Amount.Currency) {
+			return models.Promotion{}, errors.New("Fixed-amount promotions need a positive amount in a supported currency")
+		}
+		promotion.Amount = *req.Amount
+		seen := map[string]bool{req.Amount.Currency: true}
+		for _, amount := range req.Amounts {
+			switch {
+			case !money.IsCurrency(amount.Currency) || seen[amount.Currency]:
+				return models.Promotion{}, fmt.Errorf("amounts: %q is not a supported currency or is listed twice", amount.Currency)
+			case amount.Amount <= 0:
+				return models.Promotion{}, errors.New("amounts must be positive")
+			}
+			seen[amount.Currency] = true
+		}
+		promotion.Amounts = req.Amounts
+	case models.PromotionBuyXGetY:
+		if req.BuyQuantity < 1 || req.GetQuantity < 1 {
+			return models.Promotion{}, errors.New("Buy-X-get-Y promotions need buy_quantity and get_quantity of at least 1")
+		}
+		promotion.BuyQuantity, promotion.GetQuantity = req.BuyQuantity, req.GetQuantity
+	}
+
+	if m := req.MinOrderValue; m != nil && (m.Amount < 0 || !money.IsCurrency(m.Currency)) {
+		return models.Promotion{}, errors.New("min_order_value must be a non-negative amount in a supported currency")
+	}
+
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return models.Promotion{}, errors.New("ends_at must be after starts_at")
+	}
+
+	for _, hex := range req.ProductIDs {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return models.Promotion{}, errors.New("Invalid product ID " + hex)
+		}
+		promotion.ProductIDs = append(promotion.ProductIDs, id)
+	}
+	return promotion, nil
+}
+
+// ListPromotions lists all promotions, newest first.
+func ListPromotions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	cursor, err := promotionCollection().Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		respondDBError(c, err, "Failed to fetch promotions")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var promotions []models.Promotion
+	if err = cursor.All(ctx, &promotions); err != nil {
+		respondDBError(c, err, "Failed to decode promotions")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewPromotionsResponse(promotions))
+}
+
+func CreatePromotion(c *gin.Context) {
+	var req dto.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion, err := promotionFromRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	promotion.ID = primitive.NewObjectID()
+	promotion.CreatedAt = now
+	promotion.UpdatedAt = now
+
+	_, err = promotionCollection().InsertOne(c.Request.Context(), promotion)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A promotion with this code already exists"})
+		return
+	}
+	if err != nil {
+		respondDBError(c, err, "Failed to create promotion")
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.NewPromotion(promotion))
+}
+
+// UpdatePromotion replaces a promotion's settings. Its usage count is kept.
+func UpdatePromotion(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion ID"})
+		return
+	}
+
+	var req dto.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion, err := promotionFromRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	set := bson.M{
+		"code":              promotion.Code,
+		"description":       promotion.Description,
+		"type":              promotion.Type,
+		"value":             promotion.Value,
+		"buy_quantity":      promotion.BuyQuantity,
+		"get_quantity":      promotion.GetQuantity,
+		"min_order_value":   promotion.MinOrderValue,
+		"product_ids":       promotion.ProductIDs,
+		"categories":        promotion.Categories,
+		"max_uses":          promotion.MaxUses,
+		"max_uses_per_user": promotion.MaxUsesPerUser,
+		"starts_at":         promotion.StartsAt,
+		"ends_at":           promotion.EndsAt,
+		"active":            promotion.Active,
+		"updated_at":        time.Now(),
+	}
+	update := bson.M{"$set": set}
+	if promotion.Type == models.PromotionFixedAmount {
+		set["amount"] = promotion.Amount
+		set["amounts"] = promotion.Amounts
+	} else {
+		update["$unset"] = bson.M{"amount": "", "amounts": ""}
+	}
+
+	var updated models.Promotion
+	err = promotionCollection().FindOneAndUpdate(c.Request.Context(),
+		bson.M{"_id": objectID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+		return
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A promotion with this code already exists"})
+		return
+	}
+	if err != nil {
+		respondDBError(c, err, "Failed to update promotion")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewPromotion(updated))
+}
+
+// DeletePromotion removes a promotion. Carts it was applied to show the
+// coupon as no longer valid.
+func DeletePromotion(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion ID"})
+		return
+	}
+
+	result, err := promotionCollection().DeleteOne(c.Request.Context(), bson.M{"_id": objectID})
+	if err != nil {
+		respondDBError(c, err, "Failed to delete promotion")
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.MessageResponse{Message: "Promotion deleted"})
+}
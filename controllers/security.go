@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/logger"
+	"ecommerce-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const securityEventsLimit = 50
+
+func loginEventCollection() *mongo.Collection {
+	return config.GetCollection("login_events")
+}
+
+// lockoutDuration returns how long an account stays locked after its n-th
+// consecutive lockout, doubling each time up to the configured maximum.
+func lockoutDuration(cfg config.LockoutConfig, n int) time.Duration {
+	d := cfg.BaseDuration
+	for i := 1; i < n && d < cfg.MaxDuration; i++ {
+		d *= 2
+	}
+	return min(d, cfg.MaxDuration)
+}
+
+// recordFailedLogin counts a failed password attempt and locks the account
+// once the threshold is reached. It returns the lock expiry, if locked.
+func recordFailedLogin(ctx context.Context, userID primitive.ObjectID, cfg config.LockoutConfig) (*time.Time, error) {
+	var user models.User
+	err := userCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$inc": bson.M{"failed_login_attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.FailedLoginAttempts < cfg.Threshold {
+		return nil, nil
+	}
+
+	lockedUntil := time.Now().Add(lockoutDuration(cfg, user.LockoutCount+1))
+	_, err = userCollection().UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{"locked_until": lockedUntil, "failed_login_attempts": 0},
+		"$inc": bson.M{"lockout_count": 1},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &lockedUntil, nil
+}
+
+// resetLoginFailures clears the lockout state after a successful login.
+func resetLoginFailures(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := userCollection().UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set":   bson.M{"failed_login_attempts": 0, "lockout_count": 0},
+		"$unset": bson.M{"locked_until": ""},
+	})
+	return err
+}
+
+// loginAnomalies flags a successful login that differs from the user's
+// previous successful sign-ins. A user's first login is never flagged.
+func loginAnomalies(ctx context.Context, user models.User, ip, userAgent string) []string {
+	var anomalies []string
+
+	seen := func(field, value string) (bool, error) {
+		n, err := loginEventCollection().CountDocuments(ctx,
+			bson.M{"user_id": user.ID, "success": true, field: value},
+			options.Count().SetLimit(1))
+		return n > 0, err
+	}
+
+	hasHistory, err := loginEventCollection().CountDocuments(ctx,
+		bson.M{"user_id": user.ID, "success": true}, options.Count().SetLimit(1))
+	if err != nil || hasHistory == 0 {
+		return nil
+	}
+
+	if ok, err := seen("ip", ip); err == nil && !ok {
+		anomalies = append(anomalies, models.AnomalyNewIP)
+	}
+	if ok, err := seen("user_agent", userAgent); err == nil && !ok {
+		anomalies = append(anomalies, models.AnomalyNewUserAgent)
+	}
+	if user.FailedLoginAttempts > 0 {
+		anomalies = append(anomalies, models.AnomalyAfterFailures)
+	}
+	if user.LockoutCount > 0 {
+		anomalies = append(anomalies, models.AnomalyFollowsLockout)
+	}
+	return anomalies
+}
+
+// recordLoginEvent stores a login attempt made by the current request.
+// Failures are logged rather than returned so auditing never blocks login.
+// The client IP only comes from X-Forwarded-For when a trusted proxy sent
+// the request (see server.trusted_proxies).
+func recordLoginEvent(c *gin.Context, event models.LoginEvent) {
+	event.ID = primitive.NewObjectID()
+	event.IP = c.ClientIP()
+	if peer := c.RemoteIP(); peer != event.IP {
+		event.PeerIP = peer
+	}
+	event.UserAgent = c.Request.UserAgent()
+	event.CreatedAt = time.Now()
+
+	log := logger.FromContext(c.Request.Context())
+	if _, err := loginEventCollection().InsertOne(c.Request.Context(), event); err != nil {
+		log.Error("recording login event failed", "error", err)
+	}
+	if len(event.Anomalies) > 0 {
+		log.Warn("login anomaly", "email", event.Email, "anomalies", event.Anomalies, "ip", event.IP, "peer_ip", event.PeerIP)
+	}
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"net/http"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
+	"ecommerce-backend/shipping"
+	"ecommerce-backend/tax"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// shippingParcel describes the priced cart to the shipping quoter.
+func shippingParcel(cart pricedCart) shipping.Parcel {
+	parcel := shipping.Parcel{
+		Subtotal:     cart.quote.Subtotal.Sub(cart.quote.Discount),
+		FreeShipping: cart.promotion != nil && cart.discount.FreeShipping,
+	}
+	for _, item := range cart.items {
+		var volume float64
+		if d := item.Product.Dimensions; d != nil {
+			volume = d.Length * d.Width * d.Height
+		}
+		parcel.Items = append(parcel.Items, shipping.Item{Weight: item.Product.Weight, Volume: volume, Quantity: item.Quantity})
+	}
+	return parcel
+}
+
+// GetShippingOptions quotes the shipping methods available for the cart,
+// sent to the country in the query or the configured default, in the
+// cart's currency.
+func GetShippingOptions(cfg *config.Config, taxes tax.Calculator, quoter shipping.Quoter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		pricer, ok := cartPricerFromQuery(c, cfg, taxes)
+		if !ok {
+			return
+		}
+		country := pricer.address.Country
+		if country == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "country is required"})
+			return
+		}
+
+		userObjectID, _ := primitive.ObjectIDFromHex(userID.(string))
+
+		cart, err := pricer.priceCart(c.Request.Context(), userObjectID)
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch cart")
+			return
+		}
+		if len(cart.items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
+			return
+		}
+
+		options := quoter.Quote(country, shippingParcel(cart))
+		c.JSON(http.StatusOK, dto.NewShippingOptionsResponse(country, cart.quote.Currency, options))
+	}
+}
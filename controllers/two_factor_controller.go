@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/dto"
+	"ecommerce-backend/models"
+	"ecommerce-backend/password"
+	"ecommerce-backend/tokens"
+	"ecommerce-backend/totp"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const recoveryCodeCount = 10
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateRecoveryCodes returns fresh recovery codes formatted for display
+// ("abcde-fghij") together with the hashes to store.
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	for range recoveryCodeCount {
+		raw := make([]byte, 6)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryEncoding.EncodeToString(raw))
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code, ignoring case, spaces and
+// dashes so codes can be typed back loosely.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashToken(code)
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, a recovery code.
+// Accepted TOTP codes can't be reused and recovery codes are used up.
+func verifySecondFactor(ctx context.Context, user models.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		result, err := userCollection().UpdateOne(ctx,
+			bson.M{"_id": user.ID, "totp_last_step": bson.M{"$not": bson.M{"$gte": step}}},
+			bson.M{"$set": bson.M{"totp_last_step": step}},
+		)
+		if err != nil {
+			return false, err
+		}
+		return result.MatchedCount == 1, nil
+	}
+
+	if recoveryCode != "" {
+		hash := hashRecoveryCode(recoveryCode)
+		result, err := userCollection().UpdateOne(ctx,
+			bson.M{"_id": user.ID, "recovery_codes": hash},
+			bson.M{"$pull": bson.M{"recovery_codes": hash}},
+		)
+		if err != nil {
+			return false, err
+		}
+		return result.MatchedCount == 1, nil
+	}
+	return false, nil
+}
+
+// issueLoginChallenge responds to a correct password on an account with
+// two-factor authentication with a short-lived challenge token.
+func issueLoginChallenge(c *gin.Context, cfg *config.Config, user models.User) {
+	token, err := issueUserToken(c.Request.Context(), user.ID, models.TokenLoginChallenge, cfg.TwoFactor.ChallengeTTL)
+	if err != nil {
+		respondDBError(c, err, "Failed to start two-factor login")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.LoginChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresIn:         int(cfg.TwoFactor.ChallengeTTL.Seconds()),
+	})
+}
+
+// VerifyLoginChallenge is the second login step: it exchanges a challenge
+// token and a TOTP or recovery code for a JWT. Wrong codes count towards
+// the account lockout like wrong passwords.
+func VerifyLoginChallenge(cfg *config.Config, keys *tokens.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.TwoFactorLoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !checkLoginCSRF(c, cfg, req.Mode) {
+			return
+		}
+
+		ctx := c.Request.Context()
+
+		challenge, err := findUserToken(ctx, req.ChallengeToken, models.TokenLoginChallenge)
+		if errors.Is(err, errInvalidToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to verify challenge")
+			return
+		}
+
+		var user models.User
+		err = userCollection().FindOne(ctx, bson.M{"_id": challenge.UserID, "deleted_at": bson.M{"$exists": false}}).Decode(&user)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+			return
+		}
+		if err != nil {
+			respondDBError(c, err, "Failed to fetch user")
+			return
+		}
+
+		if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+			failedLogin(c, user, models.LoginReasonLocked, user.LockedUntil)
+			return
+		}
+
+		ok, err := verifySecondFactor(ctx, user, req.Code, req.RecoveryCode)
+		if err != nil {
+			respondDBError(c, err, "Failed to verify code")
+			return
+		}
+		if !ok {
+			lockedUntil, err := recordFailedLogin(ctx, user.ID, cfg.Lockout)
+			if err != nil {
+				respondDBError(c, err, "Failed to record login attempt")
+				return
+			}
+			failedLogin(c, user, models.LoginReasonInvalidCode, lockedUntil)
+			return
+		}
+
+		// Use up the challenge so it can't complete a second login
+		if _, err := consumeUserToken(ctx, req.ChallengeToken, models.TokenLoginChallenge); err != nil {
+			if errors.Is(err, errInvalidToken) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+				return
+			}
+			respondDBError(c, err, "Failed to verify challenge")
+			return
+		}
+
+		completeLogin(c, cfg, keys, user, req.Mode)
+	}
+}
+
+// SetupTwoFactor starts TOTP enrollment. The secret is kept pending until
+// ConfirmTwoFactor receives a valid code for it.
+func SetupTwoFactor(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := currentUser(c)
+		if !ok {
+			return
+		}
+		if user.TwoFactorEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			_ = c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+			return
+		}
+
+		_, err = userCollection().UpdateOne(c.Request.Context(), bson.M{"_id": user.ID}, bson.M{
+			"$set": bson.M{"pending_totp_secret": secret, "updated_at": time.Now()},
+		})
+		if err != nil {
+			respondDBError(c, err, "Failed to start two-factor setup")
+			return
+		}
+
+		c.JSON(http.StatusOK, dto.TwoFactorSetupResponse{
+			Secret:     secret,
+			OTPAuthURI: totp.URI(cfg.TwoFactor.Issuer, user.Email, secret),
+		})
+	}
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the user proves
+// their authenticator works, and returns the recovery codes.
+func ConfirmTwoFactor(c *gin.Context) {
+	var req dto.TwoFactorConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.PendingTOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+		return
+	}
+
+	step, ok := totp.Validate(user.PendingTOTPSecret, req.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		_ = c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	// Match the pending secret so a concurrent setup isn't enabled by
+	// a code for the old one
+	result, err := userCollection().UpdateOne(c.Request.Context(),
+		bson.M{"_id": user.ID, "pending_totp_secret": user.PendingTOTPSecret},
+		bson.M{
+			"$set": bson.M{
+				"two_factor_enabled": true,
+				"totp_secret":        user.PendingTOTPSecret,
+				"totp_last_step":     step,
+				"recovery_codes":     hashes,
+				"updated_at":         time.Now(),
+			},
+			"$unset": bson.M{"pending_totp_secret": ""},
+		},
+	)
+	if err != nil {
+		respondDBError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor setup changed; start again"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor turns two-factor authentication off. Users whose role
+// requires it can't disable it.
+func DisableTwoFactor(cfg *config.Config, passwords *password.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.TwoFactorDisableRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, ok := currentUser(c)
+		if !ok || !checkPassword(c, passwords, user, req.CurrentPassword) {
+			return
+		}
+		if !user.TwoFactorEnabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+			return
+		}
+		if slices.Contains(cfg.TwoFactor.RequiredRoles, user.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		ok, err := verifySecondFactor(ctx, user, req.Code, req.RecoveryCode)
+		if err != nil {
+			respondDBError(c, err, "Failed to verify code")
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+			return
+		}
+
+		_, err = userCollection().UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+			"$set":   bson.M{"two_factor_enabled": false, "updated_at": time.Now()},
+			"$unset": bson.M{"totp_secret": "", "totp_last_step": "", "recovery_codes": ""},
+		})
+		if err != nil {
+			respondDBError(c, err, "Failed to disable two-factor authentication")
+			return
+		}
+
+		c.JSON(http.StatusOK, dto.MessageResponse{Message: "Two-factor authentication disabled"})
+	}
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errInvalidToken = errors.New("invalid or expired token")
+
+func userTokenCollection() *mongo.Collection {
+	return config.GetCollection("user_tokens")
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueUserToken creates a random single-use token for the given purpose
+// and returns it. Earlier unused tokens for the same purpose are revoked
+// so only the most recent email works.
+func issueUserToken(ctx context.Context, userID primitive.ObjectID, purpose string, ttl time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	if err := revokeUserTokens(ctx, userID, purpose); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err := userTokenCollection().InsertOne(ctx, models.UserToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// validTokenFilter matches an unused, unexpired token with one of the
+// given purposes.
+func validTokenFilter(token string, purposes []string, now time.Time) bson.M {
+	return bson.M{
+		"token_hash": hashToken(token),
+		"purpose":    bson.M{"$in": purposes},
+		"used_at":    bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": now},
+	}
+}
+
+// findUserToken returns a valid token without using it up, so a request
+// can be validated before the token is consumed. It returns
+// errInvalidToken when no such token exists.
+func findUserToken(ctx context.Context, token string, purposes ...string) (models.UserToken, error) {
+	var userToken models.UserToken
+	err := userTokenCollection().FindOne(ctx, validTokenFilter(token, purposes, time.Now())).Decode(&userToken)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.UserToken{}, errInvalidToken
+	}
+	if err != nil {
+		return models.UserToken{}, err
+	}
+	return userToken, nil
+}
+
+// consumeUserToken atomically marks an unused, unexpired token with one of
+// the given purposes as used and returns it. It returns errInvalidToken
+// when no such token exists.
+func consumeUserToken(ctx context.Context, token string, purposes ...string) (models.UserToken, error) {
+	now := time.Now()
+
+	var userToken models.UserToken
+	err := userTokenCollection().FindOneAndUpdate(ctx,
+		validTokenFilter(token, purposes, now),
+		bson.M{"$set": bson.M{"used_at": now}},
+	).Decode(&userToken)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.UserToken{}, errInvalidToken
+	}
+	if err != nil {
+		return models.UserToken{}, err
+	}
+	return userToken, nil
+}
+
+// revokeUserTokens marks every unused token of a purpose as used.
+func revokeUserTokens(ctx context.Context, userID primitive.ObjectID, purpose string) error {
+	_, err := userTokenCollection().UpdateMany(ctx,
+		bson.M{"user_id": userID, "purpose": purpose, "used_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": time.Now()}},
+	)
+	return err
+}
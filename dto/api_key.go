@@ -0,0 +1,55 @@
+package dto
+
+import (
+	"time"
+
+	"ecommerce-backend/models"
+)
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=products:write orders:read"`
+}
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreatedAPIKeyResponse carries the new key. It is only shown once; the
+// server keeps a hash.
+type CreatedAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type APIKeysResponse struct {
+	APIKeys []APIKey `json:"api_keys"`
+}
+
+func NewAPIKey(key models.APIKey) APIKey {
+	return APIKey{
+		ID:         key.ID.Hex(),
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		CreatedBy:  key.CreatedBy.Hex(),
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
+
+func NewAPIKeysResponse(keys []models.APIKey) APIKeysResponse {
+	out := make([]APIKey, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, NewAPIKey(key))
+	}
+	return APIKeysResponse{APIKeys: out}
+}
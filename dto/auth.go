@@ -0,0 +1,50 @@
+package dto
+
+type RegisterRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+}
+
+// Login modes. Token returns the access token in the response body;
+// cookie sets it in an HttpOnly session cookie instead, for browsers.
+const (
+	LoginModeToken  = "token"
+	LoginModeCookie = "cookie"
+)
+
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+	Mode     string `json:"mode" binding:"omitempty,oneof=token cookie"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type AuthResponse struct {
+	Token string `json:"token"`
+	User  User   `json:"user"`
+}
+
+// SessionResponse is returned by logins in cookie mode; the token is only
+// in the session cookie.
+type SessionResponse struct {
+	User User `json:"user"`
+}
+
+// CSRFTokenResponse carries the token cookie-authenticated browsers send in
+// the CSRF header. It is also set as a cookie.
+type CSRFTokenResponse struct {
+	CSRFToken string `json:"csrf_token"`
+}
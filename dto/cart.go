@@ -0,0 +1,165 @@
+package dto
+
+import (
+	"time"
+
+	"ecommerce-backend/models"
+	"ecommerce-backend/money"
+	"ecommerce-backend/pricing"
+	"ecommerce-backend/tax"
+)
+
+type AddToCartRequest struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,min=1,max=999"`
+}
+
+// CartEntry is a stored cart line as returned after adding to the cart.
+type CartEntry struct {
+	ID        string    `json:"id"`
+	ProductID string    `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CartItem is a cart line joined with its product. UnitPrice is the
+// product's price in the cart's currency, LineTotal the price before
+// discounts and Total what the customer pays for the line, with tax. The
+// amounts are decimal numbers in the cart's currency, as v2 has always
+// returned them; the _money fields carry them exactly.
+type CartItem struct {
+	ID             string      `json:"id"`
+	Product        ProductV2   `json:"product"`
+	Quantity       int         `json:"quantity"`
+	UnitPrice      float64     `json:"unit_price"`
+	UnitPriceMoney money.Money `json:"unit_price_money"`
+	LineTotal      float64     `json:"line_total"`
+	LineTotalMoney money.Money `json:"line_total_money"`
+	Discount       float64     `json:"discount"`
+	DiscountMoney  money.Money `json:"discount_money"`
+	Tax            float64     `json:"tax"`
+	TaxMoney       money.Money `json:"tax_money"`
+	Total          float64     `json:"total"`
+	TotalMoney     money.Money `json:"total_money"`
+}
+
+// CartDiscount is one entry in the breakdown of a cart's discounts.
+type CartDiscount struct {
+	Code        string      `json:"code"`
+	Description string      `json:"description"`
+	Amount      float64     `json:"amount"`
+	AmountMoney money.Money `json:"amount_money"`
+}
+
+// TaxLine is the tax charged under one rate.
+type TaxLine struct {
+	Name        string      `json:"name"`
+	Percent     float64     `json:"percent"`
+	Amount      float64     `json:"amount"`
+	AmountMoney money.Money `json:"amount_money"`
+}
+
+// CartTotals sums the cart. With tax_included the tax is part of the
+// subtotal; otherwise it is added to the total. The cart is priced in the
+// currency asked for when every item has a price in it, otherwise in the
+// base currency with the total converted into DisplayTotal.
+type CartTotals struct {
+	Currency      string         `json:"currency"`
+	Subtotal      float64        `json:"subtotal"`
+	SubtotalMoney money.Money    `json:"subtotal_money"`
+	Discount      float64        `json:"discount"`
+	DiscountMoney money.Money    `json:"discount_money"`
+	Discounts     []CartDiscount `json:"discounts"`
+	Tax           float64        `json:"tax"`
+	TaxMoney      money.Money    `json:"tax_money"`
+	Taxes         []TaxLine      `json:"taxes"`
+	TaxIncluded   bool           `json:"tax_included"`
+	FreeShipping  bool           `json:"free_shipping"`
+	Total         float64        `json:"total"`
+	TotalMoney    money.Money    `json:"total_money"`
+	DisplayTotal  *money.Money   `json:"display_total,omitempty"`
+}
+
+// AppliedCoupon is the coupon on the cart. A coupon that no longer applies,
+// say because it expired, stays on the cart with Valid false and the reason
+// in Message, and gives no discount.
+type AppliedCoupon struct {
+	Code    string `json:"code"`
+	Valid   bool   `json:"valid"`
+	Message string `json:"message,omitempty"`
+}
+
+type CartResponse struct {
+	Cart   []CartItem     `json:"cart"`
+	Coupon *AppliedCoupon `json:"coupon,omitempty"`
+	Totals CartTotals     `json:"totals"`
+}
+
+func NewCartEntry(entry models.Cart) CartEntry {
+	return CartEntry{
+		ID:        entry.ID.Hex(),
+		ProductID: entry.ProductID.Hex(),
+		Quantity:  entry.Quantity,
+		CreatedAt: entry.CreatedAt,
+		UpdatedAt: entry.UpdatedAt,
+	}
+}
+
+// NewCartResponse returns the cart priced by quote, whose lines are
+// indexed like items.
+func NewCartResponse(items []models.CartItem, quote pricing.Quote) CartResponse {
+	out := make([]CartItem, 0, len(items))
+	for i, item := range items {
+		line := quote.Lines[i]
+		out = append(out, CartItem{
+			ID:             item.ID.Hex(),
+			Product:        NewProductV2(item.Product),
+			Quantity:       item.Quantity,
+			UnitPrice:      line.UnitPrice.Major(),
+			UnitPriceMoney: line.UnitPrice,
+			LineTotal:      line.Subtotal.Major(),
+			LineTotalMoney: line.Subtotal,
+			Discount:       line.Discount.Major(),
+			DiscountMoney:  line.Discount,
+			Tax:            line.Tax.Major(),
+			TaxMoney:       line.Tax,
+			Total:          line.Total.Major(),
+			TotalMoney:     line.Total,
+		})
+	}
+	return CartResponse{
+		Cart: out,
+		Totals: CartTotals{
+			Currency:      quote.Currency,
+			Subtotal:      quote.Subtotal.Major(),
+			SubtotalMoney: quote.Subtotal,
+			Discount:      quote.Discount.Major(),
+			DiscountMoney: quote.Discount,
+			Discounts:     []CartDiscount{},
+			Tax:           quote.Tax.Major(),
+			TaxMoney:      quote.Tax,
+			Taxes:         NewTaxLines(quote.Taxes),
+			TaxIncluded:   quote.TaxIncluded,
+			Total:         quote.Total.Major(),
+			TotalMoney:    quote.Total,
+		},
+	}
+}
+
+func NewCartDiscount(code, description string, amount money.Money) CartDiscount {
+	return CartDiscount{Code: code, Description: description, Amount: amount.Major(), AmountMoney: amount}
+}
+
+func NewTaxLines(amounts []tax.Amount) []TaxLine {
+	out := make([]TaxLine, 0, len(amounts))
+	for _, amount := range amounts {
+		out = append(out, TaxLine{
+			Name:        amount.Name,
+			Percent:     amount.Percent,
+			Amount:      amount.Amount.Major(),
+			AmountMoney: amount.Amount,
+		})
+	}
+	return out
+}
@@ -0,0 +1,6 @@
+package dto
+
+// MessageResponse is returned by endpoints that only confirm an action.
+type MessageResponse struct {
+	Message string `json:"message"`
+}
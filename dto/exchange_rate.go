@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"time"
+
+	"ecommerce-backend/models"
+)
+
+// CurrencyQuery asks for prices to be shown in a currency.
+type CurrencyQuery struct {
+	Currency string `form:"currency" binding:"omitempty,len=3"`
+}
+
+type ExchangeRateRequest struct {
+	Rate float64 `json:"rate" binding:"required,gt=0"`
+}
+
+type ExchangeRate struct {
+	Currency  string    `json:"currency"`
+	Rate      float64   `json:"rate"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExchangeRatesResponse lists how many units of each currency one unit of
+// the base currency buys.
+type ExchangeRatesResponse struct {
+	Base  string         `json:"base"`
+	Rates []ExchangeRate `json:"rates"`
+}
+
+func NewExchangeRate(rate models.ExchangeRate) ExchangeRate {
+	return ExchangeRate(rate)
+}
+
+func NewExchangeRatesResponse(base string, rates []models.ExchangeRate) ExchangeRatesResponse {
+	out := make([]ExchangeRate, 0, len(rates))
+	for _, rate := range rates {
+		out = append(out, NewExchangeRate(rate))
+	}
+	return ExchangeRatesResponse{Base: base, Rates: out}
+}
@@ -0,0 +1,25 @@
+package dto
+
+type OIDCProvider struct {
+	Name     string `json:"name"`
+	LoginURL string `json:"login_url"`
+}
+
+type OIDCProvidersResponse struct {
+	Providers []OIDCProvider `json:"providers"`
+}
+
+// OIDCLoginQuery picks how the callback signs the user in. Cookie mode,
+// the default, sets the session cookie and redirects to the storefront;
+// token mode answers the callback with the token as JSON.
+type OIDCLoginQuery struct {
+	Mode string `form:"mode" binding:"omitempty,oneof=token cookie"`
+}
+
+// OIDCCallbackQuery is the query the provider redirects back with.
+type OIDCCallbackQuery struct {
+	Code             string `form:"code"`
+	State            string `form:"state"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
@@ -0,0 +1,200 @@
+package dto
+
+import (
+	"time"
+
+	"ecommerce-backend/models"
+	"ecommerce-backend/money"
+)
+
+type Address struct {
+	Name       string `json:"name" binding:"required,max=100"`
+	Line1      string `json:"line1" binding:"required,max=200"`
+	Line2      string `json:"line2,omitempty" binding:"max=200"`
+	City       string `json:"city" binding:"required,max=100"`
+	Region     string `json:"region,omitempty" binding:"max=100"`
+	PostalCode string `json:"postal_code,omitempty" binding:"max=20"`
+	Country    string `json:"country" binding:"required,iso3166_1_alpha2"`
+}
+
+// CartQuery picks the address a cart is taxed for, defaulting to the
+// configured one, and the currency it is priced in, defaulting to the base
+// currency.
+type CartQuery struct {
+	Country  string `form:"country" binding:"omitempty,iso3166_1_alpha2"`
+	Region   string `form:"region" binding:"max=100"`
+	Currency string `form:"currency" binding:"omitempty,len=3"`
+}
+
+// CheckoutRequest places an order. The order is charged in Currency when
+// every item has a price in it, otherwise in the base currency.
+// ShippingMethod is the code of one of the cart's shipping options.
+type CheckoutRequest struct {
+	ShippingAddress Address `json:"shipping_address" binding:"required"`
+	ShippingMethod  string  `json:"shipping_method" binding:"max=50"`
+	Currency        string  `json:"currency" binding:"omitempty,len=3"`
+}
+
+// OrderLine amounts are decimal numbers in the order's currency, as v2
+// has always returned them; the _money fields carry them exactly.
+type OrderLine struct {
+	ProductID      string      `json:"product_id"`
+	Name           string      `json:"name"`
+	TaxClass       string      `json:"tax_class,omitempty"`
+	UnitPrice      float64     `json:"unit_price"`
+	UnitPriceMoney money.Money `json:"unit_price_money"`
+	Quantity       int         `json:"quantity"`
+	Subtotal       float64     `json:"subtotal"`
+	SubtotalMoney  money.Money `json:"subtotal_money"`
+	Discount       float64     `json:"discount"`
+	DiscountMoney  money.Money `json:"discount_money"`
+	Tax            float64     `json:"tax"`
+	TaxMoney       money.Money `json:"tax_money"`
+	Total          float64     `json:"total"`
+	TotalMoney     money.Money `json:"total_money"`
+}
+
+type OrderPromotion struct {
+	Code          string      `json:"code"`
+	Description   string      `json:"description"`
+	Discount      float64     `json:"discount"`
+	DiscountMoney money.Money `json:"discount_money"`
+	FreeShipping  bool        `json:"free_shipping"`
+}
+
+type OrderShipping struct {
+	Code string      `json:"code"`
+	Name string      `json:"name"`
+	Cost money.Money `json:"cost"`
+}
+
+// Order totals are decimal numbers in Currency with exact _money
+// counterparts, like OrderLine.
+type Order struct {
+	ID              string          `json:"id"`
+	Status          string          `json:"status"`
+	Currency        string          `json:"currency"`
+	Lines           []OrderLine     `json:"lines"`
+	ShippingAddress Address         `json:"shipping_address"`
+	Promotion       *OrderPromotion `json:"promotion,omitempty"`
+	Shipping        *OrderShipping  `json:"shipping,omitempty"`
+	Subtotal        float64         `json:"subtotal"`
+	SubtotalMoney   money.Money     `json:"subtotal_money"`
+	Discount        float64         `json:"discount"`
+	DiscountMoney   money.Money     `json:"discount_money"`
+	Tax             float64         `json:"tax"`
+	TaxMoney        money.Money     `json:"tax_money"`
+	Taxes           []TaxLine       `json:"taxes"`
+	TaxIncluded     bool            `json:"tax_included"`
+	Total           float64         `json:"total"`
+	TotalMoney      money.Money     `json:"total_money"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+type OrdersResponse struct {
+	Orders []Order `json:"orders"`
+}
+
+func NewAddress(address models.Address) Address {
+	return Address(address)
+}
+
+func NewOrder(order models.Order) Order {
+	lines := make([]OrderLine, 0, len(order.Lines))
+	for _, line := range order.Lines {
+		lines = append(lines, OrderLine{
+			ProductID:      line.ProductID.Hex(),
+			Name:           line.Name,
+			TaxClass:       line.TaxClass,
+			UnitPrice:      line.UnitPrice.Major(),
+			UnitPriceMoney: line.UnitPrice,
+			Quantity:       line.Quantity,
+			Subtotal:       line.Subtotal.Major(),
+			SubtotalMoney:  line.Subtotal,
+			Discount:       line.Discount.Major(),
+			DiscountMoney:  line.Discount,
+			Tax:            line.Tax.Major(),
+			TaxMoney:       line.Tax,
+			Total:          line.Total.Major(),
+			TotalMoney:     line.Total,
+		})
+	}
+	taxes := make([]TaxLine, 0, len(order.Taxes))
+	for _, t := range order.Taxes {
+		taxes = append(taxes, TaxLine{Name: t.Name, Percent: t.Percent, Amount: t.Amount.Major(), AmountMoney: t.Amount})
+	}
+
+	out := Order{
+		ID:              order.ID.Hex(),
+		Status:          order.Status,
+		Currency:        order.Total.Currency,
+		Lines:           lines,
+		ShippingAddress: NewAddress(order.ShippingAddress),
+		Subtotal:        order.Subtotal.Major(),
+		SubtotalMoney:   order.Subtotal,
+		Discount:        order.Discount.Major(),
+		DiscountMoney:   order.Discount,
+		Tax:             order.Tax.Major(),
+		TaxMoney:        order.Tax,
+		Taxes:           taxes,
+		TaxIncluded:     order.TaxIncluded,
+		Total:           order.Total.Major(),
+		TotalMoney:      order.Total,
+		CreatedAt:       order.CreatedAt,
+	}
+	if p := order.Promotion; p != nil {
+		out.Promotion = &OrderPromotion{
+			Code:          p.Code,
+			Description:   p.Description,
+			Discount:      p.Discount.Major(),
+			DiscountMoney: p.Discount,
+			FreeShipping:  p.FreeShipping,
+		}
+	}
+	if s := order.Shipping; s != nil {
+		out.Shipping = &OrderShipping{Code: s.Code, Name: s.Name, Cost: s.Cost}
+	}
+	return out
+}
+
+func NewOrdersResponse(orders []models.Order) OrdersResponse {
+	out := make([]Order, 0, len(orders))
+	for _, order := range orders {
+		out = append(out, NewOrder(order))
+	}
+	return OrdersResponse{Orders: out}
+}
+
+// AdminOrdersQuery pages through every customer's orders, newest first.
+type AdminOrdersQuery struct {
+	Status string `form:"status"`
+	Page   int    `form:"page,default=1" binding:"min=1"`
+	Limit  int    `form:"limit,default=50" binding:"min=1,max=100"`
+}
+
+// AdminOrder is an order together with the customer who placed it.
+type AdminOrder struct {
+	Order
+	UserID string `json:"user_id"`
+}
+
+type AdminOrdersResponse struct {
+	Orders     []AdminOrder `json:"orders"`
+	Pagination Pagination   `json:"pagination"`
+}
+
+func NewAdminOrdersResponse(orders []models.Order, page, limit int, total int64) AdminOrdersResponse {
+	out := make([]AdminOrder, 0, len(orders))
+	for _, order := range orders {
+		out = append(out, AdminOrder{Order: NewOrder(order), UserID: order.UserID.Hex()})
+	}
+	return AdminOrdersResponse{
+		Orders: out,
+		Pagination: Pagination{
+			Page:       page,
+			Limit:      limit,
+			Total:      total,
+			TotalPages: (total + int64(limit) - 1) / int64(limit),
+		},
+	}
+}
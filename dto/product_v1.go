@@ -0,0 +1,31 @@
+package dto
+
+import "ecommerce-backend/models"
+
+// ProductV1 is the legacy product representation consumed by the browser
+// storefront in public/app.js: integer ids and an imageUrl field.
+type ProductV1 struct {
+	ID          int64   `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	ImageURL    string  `json:"imageUrl"`
+}
+
+func NewProductV1(product models.Product) ProductV1 {
+	return ProductV1{
+		ID:          product.LegacyID,
+		Name:        product.Name,
+		Description: product.Description,
+		Price:       product.Price.Major(),
+		ImageURL:    product.Image,
+	}
+}
+
+func NewProductListV1(products []models.Product) []ProductV1 {
+	out := make([]ProductV1, 0, len(products))
+	for _, product := range products {
+		out = append(out, NewProductV1(product))
+	}
+	return out
+}
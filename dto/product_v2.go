@@ -0,0 +1,113 @@
+package dto
+
+import (
+	"time"
+
+	"ecommerce-backend/models"
+	"ecommerce-backend/money"
+)
+
+// ProductV2 is the current product representation, identified by the
+// MongoDB ObjectID in hex. Price is a decimal number in the base currency,
+// as v2 has always returned it; PriceMoney carries it exactly.
+type ProductV2 struct {
+	ID         string        `json:"id"`
+	Name       string        `json:"name"`
+	Price      float64       `json:"price"`
+	PriceMoney money.Money   `json:"price_money"`
+	Prices     []money.Money `json:"prices"`
+	// DisplayPrice is the price in the currency asked for: the price list
+	// entry, or an estimate converted at the current exchange rate.
+	DisplayPrice *money.Money `json:"display_price,omitempty"`
+	Image        string       `json:"image"`
+	Description  string       `json:"description"`
+	Category     string       `json:"category"`
+	TaxClass     string       `json:"tax_class,omitempty"`
+	Stock        int          `json:"stock"`
+	Weight       int64        `json:"weight,omitempty"` // grams
+	Dimensions   *Dimensions  `json:"dimensions,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// ProductRequest is the body of product create and update requests. An
+// update replaces every field. Price is in the base currency; Prices lists
+// prices in other currencies.
+type ProductRequest struct {
+	Name        string        `json:"name" binding:"required"`
+	Price       *money.Money  `json:"price" binding:"required"`
+	Prices      []money.Money `json:"prices"`
+	Image       string        `json:"image"`
+	Description string        `json:"description"`
+	Category    string        `json:"category" binding:"required"`
+	TaxClass    string        `json:"tax_class" binding:"max=50"`     // empty for the default class
+	Stock       *int          `json:"stock" binding:"required,min=0"` // 0 marks it out of stock
+	Weight      int64         `json:"weight" binding:"min=0"`         // grams
+	Dimensions  *Dimensions   `json:"dimensions"`
+}
+
+// Dimensions is the size of a packed product in centimetres.
+type Dimensions struct {
+	Length float64 `json:"length" binding:"gt=0"`
+	Width  float64 `json:"width" binding:"gt=0"`
+	Height float64 `json:"height" binding:"gt=0"`
+}
+
+type ProductQuery struct {
+	Search   string `form:"search"`
+	Category string `form:"category"`
+	Currency string `form:"currency" binding:"omitempty,len=3"` // adds display prices
+	Page     int    `form:"page,default=1" binding:"min=1"`
+	Limit    int    `form:"limit,default=10" binding:"min=1,max=100"`
+}
+
+type Pagination struct {
+	Page       int   `json:"page"`
+	Limit      int   `json:"limit"`
+	Total      int64 `json:"total"`
+	TotalPages int64 `json:"totalPages"`
+}
+
+type ProductListV2 struct {
+	Products   []ProductV2 `json:"products"`
+	Pagination Pagination  `json:"pagination"`
+}
+
+func NewProductV2(product models.Product) ProductV2 {
+	prices := product.Prices
+	if prices == nil {
+		prices = []money.Money{}
+	}
+	return ProductV2{
+		ID:          product.ID.Hex(),
+		Name:        product.Name,
+		Price:       product.Price.Major(),
+		PriceMoney:  product.Price,
+		Prices:      prices,
+		Image:       product.Image,
+		Description: product.Description,
+		Category:    product.Category,
+		TaxClass:    product.TaxClass,
+		Stock:       product.Stock,
+		Weight:      product.Weight,
+		Dimensions:  (*Dimensions)(product.Dimensions),
+		CreatedAt:   product.CreatedAt,
+		UpdatedAt:   product.UpdatedAt,
+	}
+}
+
+func NewProductListV2(products []models.Product, page, limit int, total int64) ProductListV2 {
+	out := make([]ProductV2, 0, len(products))
+	for _, product := range products {
+		out = append(out, NewProductV2(product))
+	}
+	return ProductListV2{
+		Products: out,
+		Pagination: Pagination{
+			Page:       page,
+			Limit:      limit,
+			Total:      total,
+			TotalPages: (total + int64(limit) - 1) / int64(limit),
+		},
+	}
+}
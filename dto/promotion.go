@@ -0,0 +1,115 @@
+package dto
+
+import (
+	"time"
+
+	"ecommerce-backend/models"
+	"ecommerce-backend/money"
+)
+
+// PromotionRequest is the body of promotion create and update requests. An
+// update replaces every field except the usage count.
+type PromotionRequest struct {
+	Code           string        `json:"code" binding:"required,max=50"`
+	Description    string        `json:"description" binding:"max=200"`
+	Type           string        `json:"type" binding:"required,oneof=percentage fixed_amount free_shipping buy_x_get_y"`
+	Value          float64       `json:"value" binding:"min=0"` // percent off, for percentage promotions
+	Amount         *money.Money  `json:"amount"`                // amount off, for fixed-amount promotions
+	Amounts        []money.Money `json:"amounts"`               // amounts off in other currencies
+	BuyQuantity    int           `json:"buy_quantity" binding:"min=0"`
+	GetQuantity    int           `json:"get_quantity" binding:"min=0"`
+	MinOrderValue  *money.Money  `json:"min_order_value"`
+	ProductIDs     []string      `json:"product_ids"`
+	Categories     []string      `json:"categories"`
+	MaxUses        int           `json:"max_uses" binding:"min=0"`
+	MaxUsesPerUser int           `json:"max_uses_per_user" binding:"min=0"`
+	StartsAt       *time.Time    `json:"starts_at"`
+	EndsAt         *time.Time    `json:"ends_at"`
+	Active         bool          `json:"active"`
+}
+
+// Promotion keeps the decimal value and min_order_value of earlier v2
+// responses; for fixed-amount promotions value is the amount off. Amount,
+// Amounts and MinOrderValueMoney carry the exact amounts.
+type Promotion struct {
+	ID                 string        `json:"id"`
+	Code               string        `json:"code"`
+	Description        string        `json:"description"`
+	Type               string        `json:"type"`
+	Value              float64       `json:"value"`
+	Amount             *money.Money  `json:"amount,omitempty"`
+	Amounts            []money.Money `json:"amounts,omitempty"`
+	BuyQuantity        int           `json:"buy_quantity,omitempty"`
+	GetQuantity        int           `json:"get_quantity,omitempty"`
+	MinOrderValue      float64       `json:"min_order_value"`
+	MinOrderValueMoney *money.Money  `json:"min_order_value_money,omitempty"`
+	ProductIDs         []string      `json:"product_ids"`
+	Categories         []string      `json:"categories"`
+	MaxUses            int           `json:"max_uses"`
+	MaxUsesPerUser     int           `json:"max_uses_per_user"`
+	TimesUsed          int           `json:"times_used"`
+	StartsAt           *time.Time    `json:"starts_at,omitempty"`
+	EndsAt             *time.Time    `json:"ends_at,omitempty"`
+	Active             bool          `json:"active"`
+	CreatedAt          time.Time     `json:"created_at"`
+	UpdatedAt          time.Time     `json:"updated_at"`
+}
+
+type PromotionsResponse struct {
+	Promotions []Promotion `json:"promotions"`
+}
+
+type ApplyCouponRequest struct {
+	Code string `json:"code" binding:"required,max=50"`
+}
+
+func NewPromotion(promotion models.Promotion) Promotion {
+	productIDs := make([]string, 0, len(promotion.ProductIDs))
+	for _, id := range promotion.ProductIDs {
+		productIDs = append(productIDs, id.Hex())
+	}
+	value := promotion.Value
+	var amount *money.Money
+	if promotion.Type == models.PromotionFixedAmount {
+		value, amount = promotion.Amount.Major(), &promotion.Amount
+	}
+	var minOrderValue float64
+	if promotion.MinOrderValue != nil {
+		minOrderValue = promotion.MinOrderValue.Major()
+	}
+	categories := promotion.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+	return Promotion{
+		ID:                 promotion.ID.Hex(),
+		Code:               promotion.Code,
+		Description:        promotion.Description,
+		Type:               promotion.Type,
+		Value:              value,
+		Amount:             amount,
+		Amounts:            promotion.Amounts,
+		BuyQuantity:        promotion.BuyQuantity,
+		GetQuantity:        promotion.GetQuantity,
+		MinOrderValue:      minOrderValue,
+		MinOrderValueMoney: promotion.MinOrderValue,
+		ProductIDs:         productIDs,
+		Categories:         categories,
+		MaxUses:            promotion.MaxUses,
+		MaxUsesPerUser:     promotion.MaxUsesPerUser,
+		TimesUsed:          promotion.TimesUsed,
+		StartsAt:           promotion.StartsAt,
+		EndsAt:             promotion.EndsAt,
+		Active:             promotion.Active,
+		CreatedAt:          promotion.CreatedAt,
+		UpdatedAt:          promotion.UpdatedAt,
+	}
+}
+
+func NewPromotionsResponse(promotions []models.Promotion) PromotionsResponse {
+	out := make([]Promotion, 0, len(promotions))
+	for _, promotion := range promotions {
+		out = append(out, NewPromotion(promotion))
+	}
+	return PromotionsResponse{Promotions: out}
+}
@@ -0,0 +1,37 @@
+package dto
+
+import (
+	"time"
+
+	"ecommerce-backend/models"
+)
+
+type SecurityEvent struct {
+	ID        string    `json:"id"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Anomalies []string  `json:"anomalies,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SecurityEventsResponse struct {
+	Events []SecurityEvent `json:"events"`
+}
+
+func NewSecurityEventsResponse(events []models.LoginEvent) SecurityEventsResponse {
+	out := make([]SecurityEvent, 0, len(events))
+	for _, event := range events {
+		out = append(out, SecurityEvent{
+			ID:        event.ID.Hex(),
+			Success:   event.Success,
+			Reason:    event.Reason,
+			IP:        event.IP,
+			UserAgent: event.UserAgent,
+			Anomalies: event.Anomalies,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+	return SecurityEventsResponse{Events: out}
+}
@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"ecommerce-backend/money"
+	"ecommerce-backend/shipping"
+)
+
+// ShippingOption is a way the cart can be shipped. FreeOver is the
+// subtotal from which it ships for free.
+type ShippingOption struct {
+	Code     string       `json:"code"`
+	Name     string       `json:"name"`
+	Cost     money.Money  `json:"cost"`
+	FreeOver *money.Money `json:"free_over,omitempty"`
+}
+
+// ShippingOptionsResponse lists the cart's shipping options for a country,
+// cheapest first, priced in the cart's currency.
+type ShippingOptionsResponse struct {
+	Country  string           `json:"country"`
+	Currency string           `json:"currency"`
+	Options  []ShippingOption `json:"options"`
+}
+
+func NewShippingOptionsResponse(country, currency string, options []shipping.Option) ShippingOptionsResponse {
+	out := make([]ShippingOption, 0, len(options))
+	for _, option := range options {
+		out = append(out, ShippingOption(option))
+	}
+	return ShippingOptionsResponse{Country: country, Currency: currency, Options: out}
+}
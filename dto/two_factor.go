@@ -0,0 +1,41 @@
+package dto
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorConfirmRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// RecoveryCodesResponse carries one-time recovery codes. They are only
+// shown once; the server keeps hashes.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorDisableRequest needs the password (or, for accounts without
+// one, a recent sign-in) and either a current code or a recovery code.
+type TwoFactorDisableRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Code            string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode    string `json:"recovery_code"`
+}
+
+// LoginChallengeResponse is returned by login instead of a token when the
+// account has two-factor authentication enabled.
+type LoginChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+// TwoFactorLoginRequest completes a login with the challenge token and
+// either a current code or a recovery code.
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode   string `json:"recovery_code"`
+	Mode           string `json:"mode" binding:"omitempty,oneof=token cookie"`
+}
@@ -0,0 +1,65 @@
+package dto
+
+import (
+	"time"
+
+	"ecommerce-backend/models"
+)
+
+// User is the public view of an account. Credentials and internal account
+// state are never included.
+type User struct {
+	ID               string    `json:"id"`
+	Email            string    `json:"email"`
+	Name             string    `json:"name"`
+	EmailVerified    bool      `json:"email_verified"`
+	Role             string    `json:"role"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	PendingEmail     string    `json:"pending_email,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+func NewUser(user models.User) User {
+	return User{
+		ID:               user.ID.Hex(),
+		Email:            user.Email,
+		Name:             user.Name,
+		EmailVerified:    user.EmailVerified,
+		Role:             user.Role,
+		TwoFactorEnabled: user.TwoFactorEnabled,
+		PendingEmail:     user.PendingEmail,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+	}
+}
+
+// UpdateProfileRequest changes the fields that are set. Changing the email
+// requires the current password (or, without one, a recent sign-in) and
+// takes effect once the new address is verified.
+type UpdateProfileRequest struct {
+	Name            *string `json:"name" binding:"omitempty,min=1"`
+	Email           *string `json:"email" binding:"omitempty,email"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+// ChangePasswordRequest needs the current password, except for accounts
+// without one, which need a recent sign-in and so can set a password.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// DeleteAccountRequest needs the current password, except for accounts
+// without one, which need a recent sign-in.
+type DeleteAccountRequest struct {
+	CurrentPassword string `json:"current_password"`
+}
+
+// TokenResponse returns a fresh token after an action that revoked the
+// caller's other sessions. Callers using a session cookie get the token in
+// a new cookie instead.
+type TokenResponse struct {
+	Message string `json:"message"`
+	Token   string `json:"token,omitempty"`
+}
@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"ecommerce-backend/logger"
+)
+
+// Job is a task run periodically in the background.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Manager runs background jobs and stops them on shutdown.
+type Manager struct {
+	mu      sync.Mutex
+	jobs    []Job
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running bool
+}
+
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// Add registers a job. Jobs added after Start are not run.
+func (m *Manager) Add(job Job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs = append(m.jobs, job)
+}
+
+// Start launches every registered job in its own goroutine.
+func (m *Manager) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.running = true
+
+	for _, job := range m.jobs {
+		m.wg.Add(1)
+		go m.loop(ctx, job)
+	}
+}
+
+func (m *Manager) loop(ctx context.Context, job Job) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	// Run once immediately, then on every tick
+	for {
+		if err := job.Run(ctx); err != nil && ctx.Err() == nil {
+			logger.Default().Error("background job failed", "job", job.Name, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop cancels all jobs and waits for them to return or for ctx to expire.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return nil
+	}
+	m.cancel()
+	m.running = false
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Running reports whether the jobs have been started and not yet stopped.
+func (m *Manager) Running() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.running
+}
@@ -1,95 +1,319 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"ecommerce-backend/config"
+	"ecommerce-backend/jobs"
+	"ecommerce-backend/logger"
+	"ecommerce-backend/mailer"
+	"ecommerce-backend/metrics"
+	"ecommerce-backend/middleware"
+	"ecommerce-backend/migrations"
+	"ecommerce-backend/models"
+	"ecommerce-backend/oidc"
+	"ecommerce-backend/password"
+	"ecommerce-backend/ratelimit"
 	"ecommerce-backend/routes"
+	"ecommerce-backend/shipping"
+	"ecommerce-backend/tax"
+	"ecommerce-backend/tokens"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
-// Product represents a product in our store
-type Product struct {
-	ID          int     `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	ImageURL    string  `json:"imageUrl"`
-}
+func main() {
+	args := os.Args[1:]
+	if len(args) >= 2 && args[0] == "config" && args[1] == "print" {
+		printConfig(args[2:])
+		return
+	}
+	if len(args) >= 2 && args[0] == "openapi" && args[1] == "check" {
+		checkOpenAPI(args[2:])
+		return
+	}
+	if len(args) >= 2 && args[0] == "user" && args[1] == "set-role" {
+		setUserRole(args[2:])
+		return
+	}
+	if len(args) >= 2 && args[0] == "jwt" && args[1] == "genkey" {
+		generateJWTKey(args[2:])
+		return
+	}
 
-// Mock database of products
-var products = []Product{
-	{
-		ID:          1,
-		Name:        "Premium Headphones",
-		Description: "High-quality sound experience with noise cancellation. Comfortable ear cups and long battery life.",
-		Price:       199.99,
-		ImageURL:    "assets/headphone.avif",
-	},
-	{
-		ID:          2,
-		Name:        "Smart Watch",
-		Description: "Track your fitness and stay connected with this feature-rich smartwatch.",
-		Price:       149.99,
-		ImageURL:    "https://images.unsplash.com/photo-1508685096489-7aacd43bd3b1?q=80&w=1527&auto=format&fit=crop&ixlib=rb-4.1.0&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D",
-	},
-	{
-		ID:          3,
-		Name:        "Wireless Charger",
-		Description: "Fast charging for all your devices. Compatible with most modern smartphones.",
-		Price:       39.99,
-		ImageURL:    "https://images.unsplash.com/photo-1737882171913-f4ced0ce73d8?q=80&w=1376&auto=format&fit=crop&ixlib=rb-4.1.0&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D",
-	},
-	{
-		ID:          4,
-		Name:        "Bluetooth Speaker",
-		Description: "Portable speaker with amazing sound quality. Water-resistant design.",
-		Price:       89.99,
-		ImageURL:    "https://via.placeholder.com/800x600?text=Bluetooth+Speaker",
-	},
-	{
-		ID:          5,
-		Name:        "Laptop Backpack",
-		Description: "Stylish and functional backpack with padded compartment for laptops up to 15 inches.",
-		Price:       59.99,
-		ImageURL:    "https://via.placeholder.com/800x600?text=Laptop+Backpack",
-	},
-}
+	// Load and validate configuration
+	cfg, err := config.Load(args)
+	if err != nil {
+		log.Fatal("Failed to load configuration: ", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
 
-func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+	// Structured logging
+	logger.Init(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	if cfg.IsProduction() {
+		gin.SetMode(gin.ReleaseMode)
 	}
 
 	// Connect to MongoDB
-	config.ConnectDB()
+	if err := config.ConnectDB(cfg.Database); err != nil {
+		log.Fatal("Failed to connect to MongoDB: ", err)
+	}
+
+	// Apply pending migrations
+	migrateCtx, cancelMigrate := context.WithTimeout(context.Background(), time.Minute)
+	err = migrations.Run(migrateCtx, config.DB, cfg)
+	cancelMigrate()
+	if err != nil {
+		log.Fatal("Failed to apply migrations: ", err)
+	}
+
+	// Background jobs
+	jobManager := jobs.NewManager()
+	jobManager.Add(jobs.Job{
+		Name:     "business_metrics",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) error {
+			return metrics.UpdateBusinessGauges(ctx, config.DB)
+		},
+	})
+
+	// Rate limiting
+	rateLimits := ratelimit.NewStore(cfg.RateLimit.Store)
+	jobManager.Add(jobs.Job{Name: "rate_limit_prune", Interval: time.Minute, Run: rateLimits.Prune})
+
+	// Outgoing email
+	mail, err := mailer.New(cfg.Mail)
+	if err != nil {
+		log.Fatal("Failed to configure mailer: ", err)
+	}
+
+	// Password policy and hashing
+	passwords, err := password.New(cfg.Password)
+	if err != nil {
+		log.Fatal("Failed to configure password policy: ", err)
+	}
+
+	// Access token signing keys
+	keys, err := tokens.NewKeyring(cfg.JWT)
+	if err != nil {
+		log.Fatal("Failed to load JWT keys: ", err)
+	}
+
+	router := newRouter(cfg, routes.Dependencies{
+		Jobs:       jobManager,
+		RateLimits: rateLimits,
+		Mailer:     mail,
+		Passwords:  passwords,
+		OIDC:       oidc.NewRegistry(cfg.OIDC, cfg.Server.PublicURL),
+		Tokens:     keys,
+		Taxes:      tax.NewTable(cfg.Tax),
+		Shipping:   shipping.NewTable(cfg.Shipping, cfg.Currency.Base),
+	})
+
+	server := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           router,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	jobManager.Start()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on port %s", cfg.Server.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	// Wait for a termination signal or a listener failure
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
-	// Initialize Gin router
-	router := gin.Default()
+	select {
+	case <-stop.Done():
+		log.Println("Shutdown signal received, draining requests")
+	case err := <-serverErr:
+		log.Println("Server failed:", err)
+	}
+
+	shutdown(server, jobManager, cfg.Server.ShutdownTimeout)
+}
 
-	// CORS middleware
-	router.Use(cors.New(cors.Config{
-		AllowAllOrigins:  true,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"*"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-	}))
+func newRouter(cfg *config.Config, deps routes.Dependencies) *gin.Engine {
+	router := gin.New()
+
+	// Only take client IPs from X-Forwarded-For when a known proxy sent it;
+	// otherwise anyone could pick the IP that rate limits and the security
+	// log see
+	var proxies []string
+	if len(cfg.Server.TrustedProxies) > 0 {
+		proxies = cfg.Server.TrustedProxies
+	}
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		log.Fatal("Invalid trusted proxies: ", err)
+	}
+
+	// Request logging, panic recovery and metrics
+	router.Use(middleware.RequestLogger(), middleware.Recovery(), middleware.MetricsMiddleware())
 
 	// Register routes
-	routes.SetupRoutes(router)
+	routes.SetupRoutes(router, cfg, deps)
+	return router
+}
+
+// shutdown drains in-flight requests, stops background jobs and closes the
+// MongoDB client, all within the given deadline.
+func shutdown(server *http.Server, jobManager *jobs.Manager, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if err := server.Shutdown(ctx); err != nil {
+		log.Println("Failed to drain HTTP server:", err)
 	}
+	if err := jobManager.Stop(ctx); err != nil {
+		log.Println("Failed to stop background jobs:", err)
+	}
+	if err := config.DisconnectDB(ctx); err != nil {
+		log.Println("Failed to disconnect MongoDB:", err)
+	}
+
+	log.Println("Server stopped")
+}
+
+// printConfig implements the "config print" command, which dumps the
+// effective configuration with secrets redacted.
+func printConfig(args []string) {
+	cfg, err := config.Load(args)
+	if err != nil {
+		log.Fatal("Failed to load configuration: ", err)
+	}
+	if err := cfg.Print(os.Stdout); err != nil {
+		log.Fatal("Failed to print configuration: ", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Println("Configuration is invalid:", err)
+		os.Exit(1)
+	}
+}
+
+// checkOpenAPI implements the "openapi check" command, which fails when a
+// registered route has no OpenAPI documentation. It needs no database.
+func checkOpenAPI(args []string) {
+	cfg, err := config.Load(args)
+	if err != nil {
+		log.Fatal("Failed to load configuration: ", err)
+	}
+
+	passwords, err := password.New(cfg.Password)
+	if err != nil {
+		log.Fatal("Failed to configure password policy: ", err)
+	}
+	keys, err := tokens.NewKeyring(cfg.JWT)
+	if err != nil {
+		log.Fatal("Failed to load JWT keys: ", err)
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := newRouter(cfg, routes.Dependencies{
+		Jobs:       jobs.NewManager(),
+		RateLimits: ratelimit.NewMemoryStore(),
+		Mailer:     mailer.NewLogMailer(cfg.Mail.From),
+		Passwords:  passwords,
+		OIDC:       oidc.NewRegistry(cfg.OIDC, cfg.Server.PublicURL),
+		Tokens:     keys,
+		Taxes:      tax.NewTable(cfg.Tax),
+		Shipping:   shipping.NewTable(cfg.Shipping, cfg.Currency.Base),
+	})
+
+	missing := routes.Docs().Undocumented(router.Routes())
+	if len(missing) > 0 {
+		for _, route := range missing {
+			fmt.Println("undocumented route:", route)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("All %d routes are documented\n", len(router.Routes()))
+}
 
-	log.Printf("Server starting on port %s", port)
-	log.Fatal(router.Run(":" + port))
+// setUserRole implements the "user set-role <email> <role>" command, which
+// grants a role such as staff or admin.
+func setUserRole(args []string) {
+	if len(args) < 2 {
+		log.Fatal("usage: user set-role <email> <customer|staff|admin> [flags]")
+	}
+	email, role := args[0], args[1]
+	if !slices.Contains([]string{models.RoleCustomer, models.RoleStaff, models.RoleAdmin}, role) {
+		log.Fatalf("Unknown role %q", role)
+	}
+
+	cfg, err := config.Load(args[2:])
+	if err != nil {
+		log.Fatal("Failed to load configuration: ", err)
+	}
+	if err := config.ConnectDB(cfg.Database); err != nil {
+		log.Fatal("Failed to connect to MongoDB: ", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	defer config.DisconnectDB(ctx)
+
+	result, err := config.GetCollection("users").UpdateOne(ctx,
+		bson.M{"email": email, "deleted_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"role": role, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		log.Fatal("Failed to update user: ", err)
+	}
+	if result.MatchedCount == 0 {
+		log.Fatalf("No user with email %s", email)
+	}
+	fmt.Printf("%s is now %s\n", email, role)
+}
+
+// generateJWTKey implements the "jwt genkey -out <file>" command, which
+// writes a new private key for access token signing.
+func generateJWTKey(args []string) {
+	flags := flag.NewFlagSet("jwt genkey", flag.ExitOnError)
+	alg := flags.String("alg", tokens.RS256, "key algorithm: RS256 or EdDSA")
+	out := flags.String("out", "", "file to write the PEM-encoded private key to")
+	_ = flags.Parse(args)
+	if *out == "" {
+		log.Fatal("usage: jwt genkey [-alg RS256|EdDSA] -out <file>")
+	}
+
+	pemData, err := tokens.GenerateKey(*alg)
+	if err != nil {
+		log.Fatal("Failed to generate key: ", err)
+	}
+	// O_EXCL so an existing (possibly active) key is never overwritten
+	file, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		log.Fatal("Failed to create key file: ", err)
+	}
+	if _, err := file.Write(pemData); err != nil {
+		file.Close()
+		log.Fatal("Failed to write key file: ", err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatal("Failed to write key file: ", err)
+	}
+	fmt.Printf("Wrote %s key to %s\n", *alg, *out)
 }
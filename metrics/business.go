@@ -0,0 +1,19 @@
+package metrics
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UpdateBusinessGauges recomputes gauges derived from stored data. It is
+// run periodically as a background job.
+func UpdateBusinessGauges(ctx context.Context, db *mongo.Database) error {
+	users, err := db.Collection("cart").Distinct(ctx, "user_id", bson.M{})
+	if err != nil {
+		return err
+	}
+	ActiveCarts.Set(float64(len(users)))
+	return nil
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// NewMongoMonitor returns a command monitor that records the latency of
+// every MongoDB command. The collection name is taken from the started
+// event, since finished events only carry the command name.
+func NewMongoMonitor() *event.CommandMonitor {
+	var collections sync.Map
+
+	observe := func(requestID int64, operation, outcome string, seconds float64) {
+		collection := "unknown"
+		if value, ok := collections.LoadAndDelete(requestID); ok {
+			collection = value.(string)
+		}
+		MongoOperationDuration.WithLabelValues(collection, operation, outcome).Observe(seconds)
+	}
+
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			// getMore carries the collection in a separate field
+			if name, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+				collections.Store(e.RequestID, name)
+			} else if name, ok := e.Command.Lookup("collection").StringValueOK(); ok {
+				collections.Store(e.RequestID, name)
+			}
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			observe(e.RequestID, e.CommandName, "success", e.Duration.Seconds())
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			observe(e.RequestID, e.CommandName, "failure", e.Duration.Seconds())
+		},
+	}
+}
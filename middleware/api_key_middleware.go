@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"ecommerce-backend/apikey"
+	"ecommerce-backend/config"
+	"ecommerce-backend/logger"
+	"ecommerce-backend/models"
+	"ecommerce-backend/tokens"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// lastUsedInterval limits how often a key's last-used time is written.
+const lastUsedInterval = time.Minute
+
+// APIKeyOrAuthMiddleware accepts an API key in the Authorization header in
+// place of a user's bearer JWT. Requests with a key get "api_key_id" and
+// "scopes" set instead of "user_id"; all other requests are handled by
+// AuthMiddleware.
+func APIKeyOrAuthMiddleware(keys *tokens.Keyring, sessionCfg config.SessionConfig) gin.HandlerFunc {
+	userAuth := AuthMiddleware(keys, sessionCfg)
+	return func(c *gin.Context) {
+		credential := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !apikey.Is(credential) {
+			userAuth(c)
+			return
+		}
+
+		ctx := c.Request.Context()
+		var key models.APIKey
+		err := config.GetCollection("api_keys").FindOne(ctx, bson.M{
+			"key_hash":   apikey.Hash(credential),
+			"revoked_at": bson.M{"$exists": false},
+		}).Decode(&key)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			_ = c.Error(err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to validate API key"})
+			c.Abort()
+			return
+		}
+
+		log := logger.FromContext(ctx).With("api_key_id", key.ID.Hex())
+		now := time.Now()
+		if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedInterval {
+			_, err := config.GetCollection("api_keys").UpdateOne(ctx,
+				bson.M{"_id": key.ID}, bson.M{"$set": bson.M{"last_used_at": now}})
+			if err != nil {
+				log.Warn("recording API key use failed", "error", err)
+			}
+		}
+
+		c.Set("api_key_id", key.ID.Hex())
+		c.Set("auth_source", AuthSourceHeader)
+		c.Set("scopes", key.Scopes)
+		c.Request = c.Request.WithContext(logger.WithContext(ctx, log))
+		c.Next()
+	}
+}
@@ -1,60 +1,120 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
-	"os"
 	"strings"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/logger"
+	"ecommerce-backend/tokens"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Where a request's credentials came from, stored as "auth_source".
+const (
+	AuthSourceHeader = "header"
+	AuthSourceCookie = "cookie"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware requires a valid access token issued by keys, either as
+// a bearer token or in the session cookie, and loads the session state of
+// its user. The Authorization header wins when both are present.
+func AuthMiddleware(keys *tokens.Keyring, sessionCfg config.SessionConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		source := AuthSourceHeader
 		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
-			return
-		}
-
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
+		if authHeader == "" {
+			cookie, err := c.Cookie(sessionCfg.CookieName)
+			if err != nil || cookie == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header or session cookie required"})
+				c.Abort()
+				return
+			}
+			source, tokenString = AuthSourceCookie, cookie
+		} else if tokenString == authHeader {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
 			c.Abort()
 			return
 		}
 
-		secret := os.Getenv("JWT_SECRET")
-		if secret == "" {
-			secret = "default-secret-key"
-		}
-
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(secret), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := keys.Parse(tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
+		userID := claims.UserID
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		// Reject tokens of deleted accounts and tokens issued before the
+		// user's sessions were revoked (password change, account deletion)
+		session, valid, err := loadSession(c.Request.Context(), userID, claims.IssuedAt.Time)
+		if err != nil {
+			_ = c.Error(err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to validate session"})
 			c.Abort()
 			return
 		}
-
-		userID, ok := claims["user_id"].(string)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
+		if !valid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired"})
 			c.Abort()
 			return
 		}
 
 		c.Set("user_id", userID)
+		c.Set("auth_source", source)
+		c.Set("role", session.Role)
+		c.Set("two_factor_enabled", session.TwoFactorEnabled)
+		c.Set("issued_at", claims.IssuedAt.Time)
+
+		// Tag the request-scoped logger with the authenticated user
+		ctx := c.Request.Context()
+		c.Request = c.Request.WithContext(logger.WithContext(ctx, logger.FromContext(ctx).With("user_id", userID)))
 		c.Next()
 	}
 }
+
+// session is the account state the middleware needs for every request.
+type session struct {
+	Role               string     `bson:"role"`
+	TwoFactorEnabled   bool       `bson:"two_factor_enabled"`
+	SessionsValidAfter *time.Time `bson:"sessions_valid_after"`
+	DeletedAt          *time.Time `bson:"deleted_at"`
+}
+
+// loadSession fetches the token owner's account state and reports whether
+// the token is still valid for it.
+func loadSession(ctx context.Context, userID string, issuedAt time.Time) (session, bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return session{}, false, nil
+	}
+
+	var user session
+	err = config.GetCollection("users").FindOne(ctx,
+		bson.M{"_id": objectID},
+		options.FindOne().SetProjection(bson.M{"role": 1, "two_factor_enabled": 1, "sessions_valid_after": 1, "deleted_at": 1}),
+	).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return session{}, false, nil
+	}
+	if err != nil {
+		return session{}, false, err
+	}
+
+	if user.DeletedAt != nil {
+		return session{}, false, nil
+	}
+	if user.SessionsValidAfter != nil && issuedAt.Unix() < user.SessionsValidAfter.Unix() {
+		return session{}, false, nil
+	}
+	return user, true, nil
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"ecommerce-backend/config"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// CORS applies cfg.Public to GET and HEAD requests under publicPaths and
+// cfg.Private to all other requests. Preflight requests are classified by
+// the method they ask for, so a preflight for a catalog write gets the
+// private policy. Cross-origin requests from origins a policy does not
+// allow are rejected with 403.
+func CORS(cfg config.CORSConfig, publicPaths []string) gin.HandlerFunc {
+	public := cors.New(corsConfig(cfg.Public))
+	private := cors.New(corsConfig(cfg.Private))
+
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		if method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			method = c.GetHeader("Access-Control-Request-Method")
+		}
+
+		if (method == http.MethodGet || method == http.MethodHead) && underAny(c.Request.URL.Path, publicPaths) {
+			public(c)
+		} else {
+			private(c)
+		}
+	}
+}
+
+func corsConfig(policy config.CORSPolicy) cors.Config {
+	cfg := cors.Config{
+		AllowMethods:     policy.AllowedMethods,
+		AllowHeaders:     policy.AllowedHeaders,
+		ExposeHeaders:    policy.ExposedHeaders,
+		AllowCredentials: policy.AllowCredentials,
+		MaxAge:           policy.MaxAge,
+	}
+	if slices.Contains(policy.AllowedOrigins, "*") {
+		cfg.AllowAllOrigins = true
+	} else {
+		cfg.AllowOriginFunc = func(origin string) bool {
+			return slices.ContainsFunc(policy.AllowedOrigins, func(pattern string) bool {
+				return originMatches(pattern, origin)
+			})
+		}
+	}
+	return cfg
+}
+
+// originMatches reports whether origin matches pattern. A "*." wildcard in
+// the pattern stands for one or more host labels, so https://*.example.com
+// matches https://shop.example.com but not https://example.com.
+func originMatches(pattern, origin string) bool {
+	if !strings.Contains(pattern, "*") {
+		return strings.EqualFold(pattern, origin)
+	}
+	prefix, suffix, _ := strings.Cut(strings.ToLower(pattern), "*")
+	origin = strings.ToLower(origin)
+	if len(origin) <= len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+	labels := origin[len(prefix) : len(origin)-len(suffix)]
+	return !strings.ContainsAny(labels, "/:@?#") && !strings.HasPrefix(labels, ".") && !strings.HasSuffix(labels, ".")
+}
+
+// underAny reports whether path is one of prefixes or below one of them.
+func underAny(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
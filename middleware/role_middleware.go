@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"ecommerce-backend/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole only lets through users with one of the given roles. Users
+// whose role is in cfg.RequiredRoles must also have two-factor
+// authentication enabled. It must run after AuthMiddleware.
+func RequireRole(cfg config.TwoFactorConfig, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		if !slices.Contains(roles, role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		if slices.Contains(cfg.RequiredRoles, role) && !c.GetBool("two_factor_enabled") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role; enable it at /api/me/2fa/setup"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireScopeOrRole lets through API keys granted scope and users allowed
+// by RequireRole(cfg, roles...). It must run after APIKeyOrAuthMiddleware.
+func RequireScopeOrRole(cfg config.TwoFactorConfig, scope string, roles ...string) gin.HandlerFunc {
+	requireRole := RequireRole(cfg, roles...)
+	return func(c *gin.Context) {
+		if c.GetString("api_key_id") == "" {
+			requireRole(c)
+			return
+		}
+
+		if !slices.Contains(c.GetStringSlice("scopes"), scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key lacks the " + scope + " scope"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
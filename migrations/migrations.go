@@ -0,0 +1,341 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/logger"
+	"ecommerce-backend/models"
+	"ecommerce-backend/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collectionName = "schema_migrations"
+
+// Migration is a one-off change to the database schema or data. Versions
+// must be unique and increasing; applied versions are recorded in the
+// schema_migrations collection. Up gets the configuration for data
+// migrations that depend on it.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database, cfg *config.Config) error
+}
+
+type record struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+var all = []Migration{
+	{
+		Version:     1,
+		Description: "unique index on users.email",
+		Up: func(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+			_, err := db.Collection("users").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "email", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})
+			return err
+		},
+	},
+	{
+		Version:     2,
+		Description: "index cart items by user and product",
+		Up: func(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+			_, err := db.Collection("cart").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "product_id", Value: 1}},
+			})
+			return err
+		},
+	},
+	{
+		Version:     3,
+		Description: "assign sequential legacy ids to products for the v1 API",
+		Up: func(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+			products := db.Collection("products")
+			opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetProjection(bson.M{"_id": 1})
+			cursor, err := products.Find(ctx, bson.M{"legacy_id": bson.M{"$exists": false}}, opts)
+			if err != nil {
+				return err
+			}
+
+			var docs []struct {
+				ID primitive.ObjectID `bson:"_id"`
+			}
+			if err := cursor.All(ctx, &docs); err != nil {
+				return err
+			}
+
+			for _, doc := range docs {
+				legacyID, err := config.NextSequence(ctx, "products")
+				if err != nil {
+					return err
+				}
+				if _, err := products.UpdateByID(ctx, doc.ID, bson.M{"$set": bson.M{"legacy_id": legacyID}}); err != nil {
+					return err
+				}
+			}
+
+			_, err = products.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "legacy_id", Value: 1}},
+				Options: options.Index().SetUnique(true).SetSparse(true),
+			})
+			return err
+		},
+	},
+	{
+		Version:     4,
+		Description: "expire rate limit buckets once they have refilled",
+		Up: func(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+			_, err := db.Collection("rate_limits").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "expires_at", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			})
+			return err
+		},
+	},
+	{
+		Version:     5,
+		Description: "index login events by user and expire them after 90 days",
+		Up: func(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+			_, err := db.Collection("login_events").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+				{Keys: bson.D{{Key: "created_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(90 * 24 * 60 * 60)},
+			})
+			return err
+		},
+	},
+	{
+		Version:     6,
+		Description: "index user tokens by hash and expire them",
+		Up: func(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+			_, err := db.Collection("user_tokens").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+				{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}}},
+				{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+			})
+			return err
+		},
+	},
+	{
+		Version:     7,
+		Description: "give existing users the customer role",
+		Up: func(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+			_, err := db.Collection("users").UpdateMany(ctx,
+				bson.M{"role": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"role": models.RoleCustomer}},
+			)
+			return err
+		},
+	},
+	{
+		Version:     8,
+		Description: "index linked OIDC identities and expire pending OIDC logins",
+		Up: func(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+			_, err := db.Collection("users").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"identities.subject": bson.M{"$exists": true}}),
+			})
+			if err != nil {
+				return err
+			}
+			_, err = db.Collection("oidc_logins").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "expires_at", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			})
+			return err
+		},
+	},
+	{
+		Version:     9,
+		Description: "unique index on API key hashes",
+		Up: func(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+			_, err := db.Collection("api_keys").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "key_hash", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})
+			return err
+		},
+	},
+	{
+		Version:     10,
+		Description: "promotion codes and redemptions",
+		Up: func(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+			_, err := db.Collection("promotions").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "code", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})
+			if err != nil {
+				return err
+			}
+			_, err = db.Collection("promotion_redemptions").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "promotion_id", Value: 1}, {Key: "user_id", Value: 1}},
+			})
+			return err
+		},
+	},
+	{
+		Version:     11,
+		Description: "index orders by user",
+		Up: func(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+			_, err := db.Collection("orders").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+			})
+			return err
+		},
+	},
+	{
+		Version:     12,
+		Description: "store prices and order amounts as integer money in the base currency",
+		Up: func(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+			base := cfg.Currency.Base
+			_, err := db.Collection("products").UpdateMany(ctx,
+				bson.M{"price": bson.M{"$type": "number"}},
+				mongo.Pipeline{{{Key: "$set", Value: bson.M{"price": legacyMoney("$price", base)}}}},
+			)
+			if err != nil {
+				return err
+			}
+
+			promotions := db.Collection("promotions")
+			_, err = promotions.UpdateMany(ctx,
+				bson.M{"min_order_value": bson.M{"$type": "number"}},
+				mongo.Pipeline{{{Key: "$set", Value: bson.M{"min_order_value": legacyMoney("$min_order_value", base)}}}},
+			)
+			if err != nil {
+				return err
+			}
+			_, err = promotions.UpdateMany(ctx,
+				bson.M{"type": models.PromotionFixedAmount, "amount": bson.M{"$exists": false}},
+				mongo.Pipeline{
+					{{Key: "$set", Value: bson.M{"amount": legacyMoney("$value", base)}}},
+					{{Key: "$unset", Value: "value"}},
+				},
+			)
+			if err != nil {
+				return err
+			}
+
+			amounts := func(fields ...string) bson.M {
+				set := bson.M{}
+				for _, field := range fields {
+					set[field] = legacyMoney("$$this."+field, base)
+				}
+				return set
+			}
+			_, err = db.Collection("orders").UpdateMany(ctx,
+				bson.M{"total": bson.M{"$type": "number"}},
+				mongo.Pipeline{{{Key: "$set", Value: bson.M{
+					"subtotal": legacyMoney("$subtotal", base),
+					"discount": legacyMoney("$discount", base),
+					"tax":      legacyMoney("$tax", base),
+					"total":    legacyMoney("$total", base),
+					"promotion": bson.M{"$cond": bson.A{
+						bson.M{"$eq": bson.A{bson.M{"$type": "$promotion"}, "object"}},
+						bson.M{"$mergeObjects": bson.A{"$promotion", bson.M{"discount": legacyMoney("$promotion.discount", base)}}},
+						"$promotion",
+					}},
+					"lines": bson.M{"$map": bson.M{"input": "$lines", "in": bson.M{"$mergeObjects": bson.A{
+						"$$this", amounts("unit_price", "subtotal", "discount", "tax", "total"),
+					}}}},
+					"taxes": bson.M{"$map": bson.M{"input": "$taxes", "in": bson.M{"$mergeObjects": bson.A{
+						"$$this", amounts("amount"),
+					}}}},
+				}}}},
+			)
+			return err
+		},
+	},
+	{
+		Version:     13,
+		Description: "count promotion uses per user",
+		Up: func(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+			cursor, err := db.Collection("promotion_redemptions").Aggregate(ctx, mongo.Pipeline{
+				{{Key: "$group", Value: bson.M{
+					"_id":   bson.D{{Key: "promotion_id", Value: "$promotion_id"}, {Key: "user_id", Value: "$user_id"}},
+					"count": bson.M{"$sum": 1},
+				}}},
+				{{Key: "$merge", Value: bson.M{"into": "promotion_usage", "whenMatched": "replace"}}},
+			})
+			if err != nil {
+				return err
+			}
+			return cursor.Close(ctx)
+		},
+	},
+}
+
+// legacyMoney is an aggregation expression converting a decimal amount,
+// as prices were stored before they carried a currency, to money in
+// currency. Amounts were then in the shop's only currency, which becomes
+// its base currency. Values that are not numbers, including missing ones,
+// are left alone.
+func legacyMoney(path, currency string) bson.M {
+	return bson.M{"$cond": bson.A{
+		bson.M{"$isNumber": path},
+		bson.M{
+			"amount":   bson.M{"$toLong": bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{path, money.FromMajor(1, currency).Amount}}, 0}}},
+			"currency": currency,
+		},
+		path,
+	}}
+}
+
+// Run applies every migration that has not been applied yet, in order.
+func Run(ctx context.Context, db *mongo.Database, cfg *config.Config) error {
+	pending, err := Pending(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		if err := m.Up(ctx, db, cfg); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		_, err := db.Collection(collectionName).InsertOne(ctx, record{
+			Version:     m.Version,
+			Description: m.Description,
+			AppliedAt:   time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("recording migration %d: %w", m.Version, err)
+		}
+		logger.FromContext(ctx).Info("applied migration", "version", m.Version, "description", m.Description)
+	}
+	return nil
+}
+
+// Pending returns the migrations that have not been applied yet.
+func Pending(ctx context.Context, db *mongo.Database) ([]Migration, error) {
+	cursor, err := db.Collection(collectionName).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("listing applied migrations: %w", err)
+	}
+
+	var applied []record
+	if err := cursor.All(ctx, &applied); err != nil {
+		return nil, fmt.Errorf("decoding applied migrations: %w", err)
+	}
+
+	done := make(map[int]bool, len(applied))
+	for _, r := range applied {
+		done[r.Version] = true
+	}
+
+	var pending []Migration
+	for _, m := range all {
+		if !done[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
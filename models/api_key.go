@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// API key scopes.
+const (
+	ScopeProductsWrite = "products:write"
+	ScopeOrdersRead    = "orders:read"
+)
+
+// APIKey authenticates a server-to-server integration. Only the SHA-256
+// hash of the key is stored, next to its prefix for display.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Name       string             `bson:"name"`
+	Prefix     string             `bson:"prefix"`
+	KeyHash    string             `bson:"key_hash"`
+	Scopes     []string           `bson:"scopes"`
+	CreatedBy  primitive.ObjectID `bson:"created_by"`
+	CreatedAt  time.Time          `bson:"created_at"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty"`
+}
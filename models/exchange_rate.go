@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// ExchangeRate is how many units of Currency one unit of the base currency
+// buys. Rates are only used to show estimated prices in other currencies.
+type ExchangeRate struct {
+	Currency  string    `bson:"_id"`
+	Rate      float64   `bson:"rate"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
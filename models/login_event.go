@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Login event failure reasons.
+const (
+	LoginReasonUnknownUser     = "unknown_user"
+	LoginReasonInvalidPassword = "invalid_password"
+	LoginReasonLocked          = "account_locked"
+	LoginReasonInvalidCode     = "invalid_2fa_code"
+)
+
+// Login anomalies flagged on successful sign-ins.
+const (
+	AnomalyNewIP          = "new_ip"
+	AnomalyNewUserAgent   = "new_user_agent"
+	AnomalyAfterFailures  = "after_failed_attempts"
+	AnomalyFollowsLockout = "follows_lockout"
+)
+
+type LoginEvent struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"-" bson:"user_id,omitempty"`
+	Email     string             `json:"email" bson:"email"`
+	IP        string             `json:"ip" bson:"ip"`
+	PeerIP    string             `json:"-" bson:"peer_ip,omitempty"` // the connecting address, when a trusted proxy forwarded the request
+	UserAgent string             `json:"user_agent" bson:"user_agent"`
+	Success   bool               `json:"success" bson:"success"`
+	Reason    string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	Anomalies []string           `json:"anomalies,omitempty" bson:"anomalies,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// OIDCLogin is the server-side state of a social login between the
+// redirect to the provider and its callback. It is keyed by the SHA-256
+// hash of the state parameter.
+type OIDCLogin struct {
+	StateHash    string    `bson:"_id"`
+	Provider     string    `bson:"provider"`
+	Nonce        string    `bson:"nonce"`
+	CodeVerifier string    `bson:"code_verifier"`
+	Mode         string    `bson:"mode"`
+	ExpiresAt    time.Time `bson:"expires_at"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"ecommerce-backend/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Order statuses.
+const (
+	OrderStatusPlaced = "placed"
+)
+
+type Address struct {
+	Name       string `bson:"name"`
+	Line1      string `bson:"line1"`
+	Line2      string `bson:"line2,omitempty"`
+	City       string `bson:"city"`
+	Region     string `bson:"region,omitempty"`
+	PostalCode string `bson:"postal_code,omitempty"`
+	Country    string `bson:"country"` // ISO 3166-1 alpha-2
+}
+
+// Order is a placed order. Product details and prices are copied from the
+// cart at checkout so later catalog changes don't alter it.
+type Order struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty"`
+	UserID          primitive.ObjectID `bson:"user_id"`
+	Status          string             `bson:"status"`
+	Lines           []OrderLine        `bson:"lines"`
+	ShippingAddress Address            `bson:"shipping_address"`
+	Promotion       *OrderPromotion    `bson:"promotion,omitempty"`
+	Shipping        *OrderShipping     `bson:"shipping,omitempty"`
+	Subtotal        money.Money        `bson:"subtotal"`
+	Discount        money.Money        `bson:"discount"`
+	Tax             money.Money        `bson:"tax"`
+	Taxes           []OrderTax         `bson:"taxes"`
+	TaxIncluded     bool               `bson:"tax_included"`
+	Total           money.Money        `bson:"total"`
+	CreatedAt       time.Time          `bson:"created_at"`
+	UpdatedAt       time.Time          `bson:"updated_at"`
+}
+
+type OrderLine struct {
+	ProductID primitive.ObjectID `bson:"product_id"`
+	Name      string             `bson:"name"`
+	TaxClass  string             `bson:"tax_class,omitempty"`
+	UnitPrice money.Money        `bson:"unit_price"`
+	Quantity  int                `bson:"quantity"`
+	Subtotal  money.Money        `bson:"subtotal"`
+	Discount  money.Money        `bson:"discount"`
+	Tax       money.Money        `bson:"tax"`
+	Total     money.Money        `bson:"total"`
+}
+
+// OrderPromotion is the coupon redeemed with an order.
+type OrderPromotion struct {
+	PromotionID  primitive.ObjectID `bson:"promotion_id"`
+	Code         string             `bson:"code"`
+	Description  string             `bson:"description"`
+	Discount     money.Money        `bson:"discount"`
+	FreeShipping bool               `bson:"free_shipping"`
+}
+
+// OrderShipping is the shipping method chosen at checkout.
+type OrderShipping struct {
+	Code string      `bson:"code"`
+	Name string      `bson:"name"`
+	Cost money.Money `bson:"cost"`
+}
+
+type OrderTax struct {
+	Name    string      `bson:"name"`
+	Percent float64     `bson:"percent"`
+	Amount  money.Money `bson:"amount"`
+}
@@ -3,24 +3,45 @@ package models
 import (
 	"time"
 
+	"ecommerce-backend/money"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Product struct {
 	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name        string             `json:"name" bson:"name" binding:"required"`
-	Price       float64            `json:"price" bson:"price" binding:"required,min=0"`
+	LegacyID    int64              `json:"-" bson:"legacy_id,omitempty"`
+	Name        string             `json:"name" bson:"name"`
+	Price       money.Money        `json:"price" bson:"price"`             // in the base currency
+	Prices      []money.Money      `json:"prices" bson:"prices,omitempty"` // price list for other currencies
 	Image       string             `json:"image" bson:"image"`
 	Description string             `json:"description" bson:"description"`
-	Category    string             `json:"category" bson:"category" binding:"required"`
-	Stock       int                `json:"stock" bson:"stock" binding:"required,min=0"`
+	Category    string             `json:"category" bson:"category"`
+	TaxClass    string             `json:"tax_class" bson:"tax_class,omitempty"` // empty for the default class
+	Stock       int                `json:"stock" bson:"stock"`
+	Weight      int64              `json:"weight" bson:"weight,omitempty"`         // grams
+	Dimensions  *Dimensions        `json:"dimensions" bson:"dimensions,omitempty"` // packed size
 	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at"`
 }
 
-type ProductQuery struct {
-	Search   string `form:"search"`
-	Category string `form:"category"`
-	Page     int    `form:"page,default=1"`
-	Limit    int    `form:"limit,default=10"`
+// Dimensions is the size of a packed product in centimetres.
+type Dimensions struct {
+	Length float64 `json:"length" bson:"length"`
+	Width  float64 `json:"width" bson:"width"`
+	Height float64 `json:"height" bson:"height"`
+}
+
+// PriceIn returns the product's price in currency: the base price or the
+// price list entry. It reports false when the product has no price there.
+func (p Product) PriceIn(currency string) (money.Money, bool) {
+	if p.Price.Currency == currency {
+		return p.Price, true
+	}
+	for _, price := range p.Prices {
+		if price.Currency == currency {
+			return price, true
+		}
+	}
+	return money.Money{}, false
 }
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"time"
+
+	"ecommerce-backend/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Promotion types.
+const (
+	PromotionPercentage   = "percentage"
+	PromotionFixedAmount  = "fixed_amount"
+	PromotionFreeShipping = "free_shipping"
+	PromotionBuyXGetY     = "buy_x_get_y"
+)
+
+// Promotion is a coupon customers apply to their cart by code. Discounts
+// only apply to items in scope: the listed products and categories, or the
+// whole cart when both are empty.
+type Promotion struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Code        string             `bson:"code"` // stored upper-case
+	Description string             `bson:"description"`
+	Type        string             `bson:"type"`
+	// Value is the percentage off for percentage coupons.
+	Value float64 `bson:"value"`
+	// Amount is the amount off for fixed-amount coupons, and Amounts the
+	// amounts off in other currencies. The coupon only applies to carts
+	// priced in one of these currencies.
+	Amount  money.Money   `bson:"amount,omitempty"`
+	Amounts []money.Money `bson:"amounts,omitempty"`
+	// BuyQuantity and GetQuantity configure buy-X-get-Y: for every X+Y
+	// items in scope, the Y cheapest are free.
+	BuyQuantity    int                  `bson:"buy_quantity,omitempty"`
+	GetQuantity    int                  `bson:"get_quantity,omitempty"`
+	MinOrderValue  *money.Money         `bson:"min_order_value,omitempty"`
+	ProductIDs     []primitive.ObjectID `bson:"product_ids,omitempty"`
+	Categories     []string             `bson:"categories,omitempty"`
+	MaxUses        int                  `bson:"max_uses"`          // 0 is unlimited
+	MaxUsesPerUser int                  `bson:"max_uses_per_user"` // 0 is unlimited
+	TimesUsed      int                  `bson:"times_used"`
+	StartsAt       *time.Time           `bson:"starts_at,omitempty"`
+	EndsAt         *time.Time           `bson:"ends_at,omitempty"`
+	Active         bool                 `bson:"active"`
+	CreatedAt      time.Time            `bson:"created_at"`
+	UpdatedAt      time.Time            `bson:"updated_at"`
+}
+
+// AmountIn returns the amount off a fixed-amount coupon gives in currency.
+// It reports false when the coupon lists no amount there.
+func (p Promotion) AmountIn(currency string) (money.Money, bool) {
+	if p.Amount.Currency == currency {
+		return p.Amount, true
+	}
+	for _, amount := range p.Amounts {
+		if amount.Currency == currency {
+			return amount, true
+		}
+	}
+	return money.Money{}, false
+}
+
+// PromotionRedemption records one use of a promotion by a user.
+type PromotionRedemption struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	PromotionID primitive.ObjectID `bson:"promotion_id"`
+	UserID      primitive.ObjectID `bson:"user_id"`
+	OrderID     primitive.ObjectID `bson:"order_id"`
+	CreatedAt   time.Time          `bson:"created_at"`
+}
+
+// PromotionUsage counts a user's uses of a promotion, so checkout can claim
+// a use within the per-user limit in one atomic update.
+type PromotionUsage struct {
+	ID    PromotionUsageKey `bson:"_id"`
+	Count int               `bson:"count"`
+}
+
+type PromotionUsageKey struct {
+	PromotionID primitive.ObjectID `bson:"promotion_id"`
+	UserID      primitive.ObjectID `bson:"user_id"`
+}
+
+// CartCoupon is the coupon a user has applied to their cart. There is at
+// most one per user, keyed by the user's ID.
+type CartCoupon struct {
+	UserID      primitive.ObjectID `bson:"_id"`
+	PromotionID primitive.ObjectID `bson:"promotion_id"`
+	Code        string             `bson:"code"`
+	AppliedAt   time.Time          `bson:"applied_at"`
+}
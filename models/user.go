@@ -6,21 +6,49 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// User roles. Staff and admins can edit the catalog.
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
+// User is a stored account. It is never bound from or written to HTTP
+// directly; see dto.User and the request types in the dto package.
 type User struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Email     string             `json:"email" bson:"email" binding:"required,email"`
-	Password  string             `json:"password,omitempty" bson:"password" binding:"required,min=6"`
-	Name      string             `json:"name" bson:"name" binding:"required"`
-	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
-}
+	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Email         string             `json:"email" bson:"email"`
+	Password      string             `json:"-" bson:"password"`
+	Name          string             `json:"name" bson:"name"`
+	EmailVerified bool               `json:"email_verified" bson:"email_verified"`
+	Role          string             `json:"role" bson:"role"`
+	CreatedAt     time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at" bson:"updated_at"`
+
+	// Account state, never exposed over the API
+	PendingEmail        string     `json:"-" bson:"pending_email,omitempty"`
+	FailedLoginAttempts int        `json:"-" bson:"failed_login_attempts"`
+	LockoutCount        int        `json:"-" bson:"lockout_count"`
+	LockedUntil         *time.Time `json:"-" bson:"locked_until,omitempty"`
+	SessionsValidAfter  *time.Time `json:"-" bson:"sessions_valid_after,omitempty"`
+	DeletedAt           *time.Time `json:"-" bson:"deleted_at,omitempty"`
+
+	// Two-factor authentication. TOTPLastStep is the time step of the last
+	// accepted code, so a code can't be replayed. RecoveryCodes holds
+	// SHA-256 hashes of the unused recovery codes.
+	TwoFactorEnabled  bool     `json:"-" bson:"two_factor_enabled"`
+	TOTPSecret        string   `json:"-" bson:"totp_secret,omitempty"`
+	PendingTOTPSecret string   `json:"-" bson:"pending_totp_secret,omitempty"`
+	TOTPLastStep      int64    `json:"-" bson:"totp_last_step,omitempty"`
+	RecoveryCodes     []string `json:"-" bson:"recovery_codes,omitempty"`
 
-type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	// External identities (OIDC providers) linked to the account
+	Identities []ExternalIdentity `json:"-" bson:"identities,omitempty"`
 }
 
-type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+// ExternalIdentity links an account to a subject at an OIDC provider.
+type ExternalIdentity struct {
+	Provider string    `bson:"provider"`
+	Subject  string    `bson:"subject"`
+	LinkedAt time.Time `bson:"linked_at"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// User token purposes.
+const (
+	TokenVerifyEmail    = "verify_email"
+	TokenChangeEmail    = "change_email"
+	TokenResetPassword  = "reset_password"
+	TokenLoginChallenge = "login_challenge"
+)
+
+// UserToken is a single-use token emailed to a user, or handed out as the
+// challenge between the two login steps. Only the SHA-256
+// hash of the token is stored.
+type UserToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Purpose   string             `bson:"purpose"`
+	TokenHash string             `bson:"token_hash"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
@@ -0,0 +1,178 @@
+// Package money represents amounts of money exactly, as whole numbers of a
+// currency's minor unit (cents for USD, yen for JPY).
+package money
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// minorUnits maps the supported ISO 4217 currencies to the number of
+// decimal places of their minor unit.
+var minorUnits = map[string]int{
+	"AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2,
+	"DKK": 2, "EUR": 2, "GBP": 2, "HKD": 2, "INR": 2, "JPY": 0, "KRW": 0,
+	"KWD": 3, "MXN": 2, "NOK": 2, "NZD": 2, "PLN": 2, "SEK": 2, "SGD": 2,
+	"USD": 2, "ZAR": 2,
+}
+
+// Money is an amount in a currency. Arithmetic between amounts in
+// different currencies is a programming error and panics.
+type Money struct {
+	Amount   int64  `json:"amount" bson:"amount"` // in minor units
+	Currency string `json:"currency" bson:"currency"`
+}
+
+// IsCurrency reports whether code is a supported ISO 4217 currency code.
+func IsCurrency(code string) bool {
+	_, ok := minorUnits[code]
+	return ok
+}
+
+// New returns amount minor units of currency.
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Zero returns no money in currency.
+func Zero(currency string) Money {
+	return Money{Currency: currency}
+}
+
+// FromMajor converts an amount in major units, such as dollars, rounding
+// to the nearest minor unit. It is meant for legacy float data only.
+func FromMajor(value float64, currency string) Money {
+	return Money{Amount: int64(math.Round(value * scale(currency))), Currency: currency}
+}
+
+// Major returns the amount in major units, for clients that expect a plain
+// number.
+func (m Money) Major() float64 {
+	return float64(m.Amount) / scale(m.Currency)
+}
+
+func scale(currency string) float64 {
+	return math.Pow10(minorUnits[currency])
+}
+
+func (m Money) check(other Money) {
+	if m.Currency != other.Currency {
+		panic(fmt.Sprintf("money: mixing %s and %s", m.Currency, other.Currency))
+	}
+}
+
+func (m Money) Add(other Money) Money {
+	m.check(other)
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}
+}
+
+func (m Money) Sub(other Money) Money {
+	m.check(other)
+	return Money{Amount: m.Amount - other.Amount, Currency: m.Currency}
+}
+
+// Mul returns the amount times n, such as a unit price times a quantity.
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// MulRatio returns the amount times num/den, rounded half away from zero.
+func (m Money) MulRatio(num, den int64) Money {
+	return Money{Amount: divRound(m.Amount*num, den), Currency: m.Currency}
+}
+
+// Cmp compares two amounts in the same currency, returning -1, 0 or +1.
+func (m Money) Cmp(other Money) int {
+	m.check(other)
+	switch {
+	case m.Amount < other.Amount:
+		return -1
+	case m.Amount > other.Amount:
+		return 1
+	}
+	return 0
+}
+
+// Min returns the smaller of two amounts in the same currency.
+func (m Money) Min(other Money) Money {
+	if m.Cmp(other) > 0 {
+		return other
+	}
+	return m
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Allocate splits the amount in proportion to weights. The parts add up to
+// the amount exactly; minor units left over by rounding down go to the
+// first parts. All weights zero gives all-zero parts.
+func (m Money) Allocate(weights []int64) []Money {
+	parts := make([]Money, len(weights))
+	var total int64
+	for _, w := range weights {
+		total += w
+	}
+	for i := range parts {
+		parts[i] = Zero(m.Currency)
+	}
+	if total == 0 {
+		return parts
+	}
+
+	remaining := m.Amount
+	for i, w := range weights {
+		parts[i].Amount = m.Amount * w / total
+		remaining -= parts[i].Amount
+	}
+	for i := 0; remaining != 0; i = (i + 1) % len(parts) {
+		if weights[i] == 0 {
+			continue
+		}
+		step := int64(1)
+		if remaining < 0 {
+			step = -1
+		}
+		parts[i].Amount += step
+		remaining -= step
+	}
+	return parts
+}
+
+// Decimal formats the amount in major units with the currency's decimal
+// places, such as "19.99".
+func (m Money) Decimal() string {
+	digits := minorUnits[m.Currency]
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	s := strconv.FormatInt(amount, 10)
+	if digits == 0 {
+		return sign + s
+	}
+	if len(s) <= digits {
+		s = strings.Repeat("0", digits-len(s)+1) + s
+	}
+	return sign + s[:len(s)-digits] + "." + s[len(s)-digits:]
+}
+
+// String formats the amount with its currency, such as "19.99 USD".
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// divRound divides, rounding half away from zero.
+func divRound(a, b int64) int64 {
+	if b < 0 {
+		a, b = -a, -b
+	}
+	if a < 0 {
+		return -((-a + b/2) / b)
+	}
+	return (a + b/2) / b
+}
@@ -0,0 +1,130 @@
+package money
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMulRatio(t *testing.T) {
+	tests := []struct {
+		amount, num, den int64
+		want             int64
+	}{
+		{1000, 1, 4, 250},
+		{10, 1, 4, 3},   // 2.5 rounds away from zero
+		{-10, 1, 4, -3}, // -2.5 rounds away from zero
+		{9, 1, 4, 2},    // 2.25 rounds down
+		{1000, 7, 3, 2333},
+		{10, 1, -4, -3},
+		{0, 5, 7, 0},
+	}
+	for _, tt := range tests {
+		got := New(tt.amount, "USD").MulRatio(tt.num, tt.den)
+		if got != New(tt.want, "USD") {
+			t.Errorf("%d × %d/%d = %v, want %d", tt.amount, tt.num, tt.den, got, tt.want)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		weights []int64
+		want    []int64
+	}{
+		{"even split", 90, []int64{1, 1, 1}, []int64{30, 30, 30}},
+		{"remainder to the first parts", 100, []int64{1, 1, 1}, []int64{34, 33, 33}},
+		{"proportional", 1000, []int64{1999, 999}, []int64{667, 333}},
+		{"zero weights get nothing", 1, []int64{0, 1, 1}, []int64{0, 1, 0}},
+		{"all weights zero", 100, []int64{0, 0}, []int64{0, 0}},
+		{"negative amount", -100, []int64{1, 1, 1}, []int64{-34, -33, -33}},
+		{"single part", 1234, []int64{7}, []int64{1234}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := New(tt.amount, "EUR").Allocate(tt.weights)
+			got := make([]int64, len(parts))
+			for i, part := range parts {
+				if part.Currency != "EUR" {
+					t.Fatalf("part %d in %s, want EUR", i, part.Currency)
+				}
+				got[i] = part.Amount
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Allocate(%d, %v) = %v, want %v", tt.amount, tt.weights, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocateAddsUp(t *testing.T) {
+	weights := []int64{333, 1, 0, 2500, 17, 17}
+	for amount := int64(-500); amount <= 5000; amount += 7 {
+		var sum int64
+		for _, part := range New(amount, "USD").Allocate(weights) {
+			sum += part.Amount
+		}
+		if sum != amount {
+			t.Fatalf("parts of %d add up to %d", amount, sum)
+		}
+	}
+}
+
+func TestMinorUnits(t *testing.T) {
+	tests := []struct {
+		major    float64
+		currency string
+		amount   int64
+		decimal  string
+	}{
+		{199.99, "USD", 19999, "199.99"},
+		{0.05, "EUR", 5, "0.05"},
+		{-1.5, "USD", -150, "-1.50"},
+		{2980, "JPY", 2980, "2980"},
+		{1.234, "BHD", 1234, "1.234"},
+	}
+	for _, tt := range tests {
+		m := FromMajor(tt.major, tt.currency)
+		if m.Amount != tt.amount {
+			t.Errorf("FromMajor(%v, %s) = %d, want %d", tt.major, tt.currency, m.Amount, tt.amount)
+		}
+		if got := m.Decimal(); got != tt.decimal {
+			t.Errorf("%d %s formats as %q, want %q", tt.amount, tt.currency, got, tt.decimal)
+		}
+	}
+}
+
+func TestMixingCurrenciesPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("adding USD to EUR did not panic")
+		}
+	}()
+	New(100, "USD").Add(New(100, "EUR"))
+}
+
+func TestConvert(t *testing.T) {
+	rates := Rates{Base: "USD", Rates: map[string]float64{"EUR": 0.92, "JPY": 150}}
+
+	tests := []struct {
+		from     Money
+		currency string
+		want     Money
+		ok       bool
+	}{
+		{New(1000, "USD"), "EUR", New(920, "EUR"), true},
+		{New(920, "EUR"), "USD", New(1000, "USD"), true},
+		{New(1000, "USD"), "JPY", New(1500, "JPY"), true},
+		{New(920, "EUR"), "JPY", New(1500, "JPY"), true},
+		{New(1000, "GBP"), "GBP", New(1000, "GBP"), true},
+		{New(1000, "USD"), "GBP", Money{}, false},
+		{New(1000, "GBP"), "USD", Money{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := rates.Convert(tt.from, tt.currency)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Convert(%v, %s) = %v, %v; want %v, %v", tt.from, tt.currency, got, ok, tt.want, tt.ok)
+		}
+	}
+}
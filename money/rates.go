@@ -0,0 +1,38 @@
+package money
+
+import "math"
+
+// Rates converts between currencies for display. Rates[c] is how many
+// units of c one unit of Base buys. Converted amounts are estimates; prices
+// are only charged in currencies they are listed in.
+type Rates struct {
+	Base  string
+	Rates map[string]float64
+}
+
+// rate returns how many units of currency one unit of the base buys.
+func (r Rates) rate(currency string) (float64, bool) {
+	if currency == r.Base {
+		return 1, true
+	}
+	rate, ok := r.Rates[currency]
+	return rate, ok && rate > 0
+}
+
+// Convert converts m to currency, rounding to the nearest minor unit. It
+// reports false when either currency has no rate.
+func (r Rates) Convert(m Money, currency string) (Money, bool) {
+	if m.Currency == currency {
+		return m, true
+	}
+	from, ok := r.rate(m.Currency)
+	if !ok {
+		return Money{}, false
+	}
+	to, ok := r.rate(currency)
+	if !ok {
+		return Money{}, false
+	}
+	major := m.Major() / from * to
+	return Money{Amount: int64(math.Round(major * scale(currency))), Currency: currency}, true
+}
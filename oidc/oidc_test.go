@@ -0,0 +1,148 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/oidc"
+	"ecommerce-backend/oidc/oidctest"
+)
+
+const redirectURL = "http://shop.test/api/auth/oidc/mock/callback"
+
+// authorize starts a login at the mock provider and returns the code and
+// state it redirects back with.
+func authorize(t *testing.T, provider *oidc.Provider, state, nonce, verifier string) (string, string) {
+	t.Helper()
+
+	authURL, err := provider.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if got := location.Scheme + "://" + location.Host + location.Path; got != redirectURL {
+		t.Fatalf("redirected to %s, want %s", got, redirectURL)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestExchange(t *testing.T) {
+	verified := oidctest.User{Subject: "user-1", Email: "jane@example.com", EmailVerified: true, Name: "Jane"}
+
+	tests := []struct {
+		name     string
+		user     oidctest.User
+		verifier string // sent with the code; empty to send the right one
+		nonce    string // expected by the relying party; empty for the right one
+		wantErr  bool
+	}{
+		{name: "verified email", user: verified},
+		{name: "unverified email", user: oidctest.User{Subject: "user-2", Email: "joe@example.com"}},
+		{name: "wrong PKCE verifier", user: verified, verifier: "another-verifier", wantErr: true},
+		{name: "nonce mismatch", user: verified, nonce: "another-nonce", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, server, err := oidctest.NewServer("gommerce")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+			mock.SetUser(tt.user)
+
+			provider := oidc.NewProvider(config.OIDCProviderConfig{
+				Name:     "mock",
+				Issuer:   mock.Issuer,
+				ClientID: "gommerce",
+			}, redirectURL, server.Client())
+
+			code, state := authorize(t, provider, "state-1", "nonce-1", "verifier-1")
+			if state != "state-1" {
+				t.Fatalf("state = %q, want state-1", state)
+			}
+
+			verifier, nonce := "verifier-1", "nonce-1"
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+
+			claims, err := provider.Exchange(context.Background(), code, verifier, nonce)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Exchange succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+
+			want := oidc.Claims(tt.user)
+			if claims != want {
+				t.Errorf("claims = %+v, want %+v", claims, want)
+			}
+		})
+	}
+}
+
+func TestExchangeCodeIsSingleUse(t *testing.T) {
+	mock, server, err := oidctest.NewServer("gommerce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	provider := oidc.NewProvider(config.OIDCProviderConfig{Name: "mock", Issuer: mock.Issuer, ClientID: "gommerce"},
+		redirectURL, server.Client())
+
+	code, _ := authorize(t, provider, "state-1", "nonce-1", "verifier-1")
+	if _, err := provider.Exchange(context.Background(), code, "verifier-1", "nonce-1"); err != nil {
+		t.Fatalf("first Exchange: %v", err)
+	}
+	if _, err := provider.Exchange(context.Background(), code, "verifier-1", "nonce-1"); err == nil {
+		t.Fatal("second Exchange of the same code succeeded")
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	mock, server, err := oidctest.NewServer("gommerce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	provider := oidc.NewProvider(config.OIDCProviderConfig{
+		Name:     "mock",
+		Issuer:   mock.Issuer + "/",
+		ClientID: "gommerce",
+	}, redirectURL, server.Client())
+
+	_, err = provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("AuthCodeURL error = %v, want an issuer mismatch", err)
+	}
+}
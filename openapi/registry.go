@@ -0,0 +1,188 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var pathParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// Operation documents one route. Query, Request and Response hold zero
+// values of the types bound or returned by the handler; their schemas are
+// derived from json, form and binding struct tags.
+type Operation struct {
+	Summary    string
+	Tags       []string
+	Auth       bool
+	Deprecated bool
+	Query      any
+	Request    any
+	Response   any
+	Status     int
+	// ContentType overrides application/json for non-JSON responses.
+	ContentType string
+}
+
+// Registry holds route documentation keyed by method and gin path.
+type Registry struct {
+	operations map[string]Operation
+	aliases    map[string]string
+	ignored    map[string]bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		operations: map[string]Operation{},
+		aliases:    map[string]string{},
+		ignored:    map[string]bool{},
+	}
+}
+
+// Add documents the route registered with the given method and gin path,
+// e.g. GET /api/v2/products/:id.
+func (r *Registry) Add(method, path string, op Operation) {
+	r.operations[method+" "+path] = op
+}
+
+// Alias makes routes under prefix share the documentation of the same
+// routes under target, e.g. /api/ as an alias of /api/v2/.
+func (r *Registry) Alias(prefix, target string) {
+	r.aliases[prefix] = target
+}
+
+// Ignore marks routes that are not part of the API, such as HTML pages and
+// static files, by gin path. They are never reported as undocumented.
+func (r *Registry) Ignore(paths ...string) {
+	for _, path := range paths {
+		r.ignored[path] = true
+	}
+}
+
+func (r *Registry) lookup(method, path string) (Operation, bool, bool) {
+	if op, ok := r.operations[method+" "+path]; ok {
+		return op, false, true
+	}
+	for prefix, target := range r.aliases {
+		if rest, ok := strings.CutPrefix(path, prefix); ok {
+			if op, ok := r.operations[method+" "+target+rest]; ok {
+				return op, true, true
+			}
+		}
+	}
+	return Operation{}, false, false
+}
+
+// Undocumented returns the routes that have no documentation, formatted
+// as "METHOD path" and sorted.
+func (r *Registry) Undocumented(routes gin.RoutesInfo) []string {
+	var missing []string
+	for _, route := range routes {
+		if _, _, ok := r.lookup(route.Method, route.Path); !ok && !r.ignored[route.Path] {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Build generates the OpenAPI document for the registered routes.
+// Undocumented routes are left out; use Undocumented to detect them.
+func (r *Registry) Build(routes gin.RoutesInfo, info Info) *Document {
+	builder := &schemaBuilder{components: map[string]*Schema{}}
+	builder.schemaFor(reflect.TypeOf(ErrorResponse{}))
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: builder.components,
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	for _, route := range routes {
+		op, alias, ok := r.lookup(route.Method, route.Path)
+		if !ok {
+			continue
+		}
+
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = builder.operation(route, path, op, alias)
+	}
+	return doc
+}
+
+// ErrorResponse is the body returned by every failing request.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func (b *schemaBuilder) operation(route gin.RouteInfo, path string, op Operation, alias bool) *OperationObject {
+	out := &OperationObject{
+		Summary:     op.Summary,
+		Tags:        op.Tags,
+		OperationID: operationID(route.Method, path),
+		Responses:   map[string]Response{},
+		Deprecated:  op.Deprecated || alias,
+	}
+
+	for _, match := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+		out.Parameters = append(out.Parameters, Parameter{
+			Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"},
+		})
+	}
+	if op.Query != nil {
+		out.Parameters = append(out.Parameters, b.queryParameters(reflect.TypeOf(op.Query))...)
+	}
+
+	if op.Request != nil {
+		out.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: b.schemaFor(reflect.TypeOf(op.Request))}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status)}
+	if op.Response != nil {
+		contentType := op.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		success.Content = map[string]MediaType{contentType: {Schema: b.schemaFor(reflect.TypeOf(op.Response))}}
+	}
+	out.Responses[strconv.Itoa(status)] = success
+	out.Responses["default"] = Response{
+		Description: "Error",
+		Content:     map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/ErrorResponse"}}},
+	}
+
+	if op.Auth {
+		out.Security = []map[string][]string{{"bearerAuth": {}}}
+	}
+	return out
+}
+
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '{' || r == '}' || r == '.' || r == '-' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,8 @@
+package openapi
+
+import _ "embed"
+
+// SwaggerUI is an HTML page rendering /api/openapi.json with Swagger UI.
+//
+//go:embed swagger.html
+var SwaggerUI []byte
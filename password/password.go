@@ -0,0 +1,203 @@
+// Package password hashes and verifies passwords and enforces the password
+// policy.
+package password
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"ecommerce-backend/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hash algorithm names, as used in configuration.
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+// bcryptMaxBytes is the input length bcrypt silently truncates to.
+const bcryptMaxBytes = 72
+
+//go:embed breached.txt
+var defaultBreached string
+
+// PolicyError explains why a password was rejected. Its message is safe to
+// return to clients.
+type PolicyError struct {
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return e.Reason
+}
+
+// Manager enforces the password policy and hashes and verifies passwords.
+// Stored hashes identify their algorithm by prefix ("$2a$"/"$2b$" for
+// bcrypt, "$argon2id$" for argon2id), so hashes made with older settings
+// keep verifying and can be upgraded on the next login.
+type Manager struct {
+	cfg      config.PasswordConfig
+	breached map[string]bool
+}
+
+// New builds a manager from cfg, loading the breached-password list from
+// cfg.BreachedListFile in addition to the built-in list.
+func New(cfg config.PasswordConfig) (*Manager, error) {
+	m := &Manager{cfg: cfg, breached: map[string]bool{}}
+	m.addBreached(strings.NewReader(defaultBreached))
+
+	if cfg.BreachedListFile != "" {
+		f, err := os.Open(cfg.BreachedListFile)
+		if err != nil {
+			return nil, fmt.Errorf("opening breached password list: %w", err)
+		}
+		defer f.Close()
+		if err := m.addBreached(f); err != nil {
+			return nil, fmt.Errorf("reading breached password list: %w", err)
+		}
+	}
+	return m, nil
+}
+
+func (m *Manager) addBreached(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			m.breached[strings.ToLower(line)] = true
+		}
+	}
+	return scanner.Err()
+}
+
+// Validate checks password against the policy for the account with the
+// given email. It returns a *PolicyError describing the first violation.
+func (m *Manager) Validate(password, email string) error {
+	length := utf8.RuneCountInString(password)
+	if length < m.cfg.MinLength {
+		return &PolicyError{fmt.Sprintf("Password must be at least %d characters", m.cfg.MinLength)}
+	}
+	if length > m.cfg.MaxLength || (m.cfg.Algorithm == Bcrypt && len(password) > bcryptMaxBytes) {
+		return &PolicyError{fmt.Sprintf("Password must be at most %d characters", min(m.cfg.MaxLength, bcryptMaxBytes))}
+	}
+
+	lower := strings.ToLower(password)
+	if email != "" {
+		local, _, _ := strings.Cut(strings.ToLower(email), "@")
+		if strings.Contains(lower, strings.ToLower(email)) || (len(local) >= 3 && strings.Contains(lower, local)) {
+			return &PolicyError{"Password must not contain your email address"}
+		}
+	}
+	if m.breached[lower] {
+		return &PolicyError{"Password is too common and has appeared in data breaches"}
+	}
+	return nil
+}
+
+// Hash hashes password with the configured algorithm.
+func (m *Manager) Hash(password string) (string, error) {
+	if m.cfg.Algorithm == Argon2id {
+		return m.hashArgon2id(password)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), m.cfg.BcryptCost)
+	return string(hash), err
+}
+
+// Verify reports whether password matches hash, and whether the hash was
+// made with an algorithm or parameters other than the configured ones and
+// should be replaced with a fresh Hash.
+func (m *Manager) Verify(hash, password string) (ok, needsRehash bool) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, false
+		}
+		computed := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false
+		}
+		return true, m.cfg.Algorithm != Argon2id || params != m.argon2Params()
+
+	case strings.HasPrefix(hash, "$2"):
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			return false, false
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return true, m.cfg.Algorithm != Bcrypt || err != nil || cost < m.cfg.BcryptCost
+
+	default:
+		return false, false
+	}
+}
+
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+func (m *Manager) argon2Params() argon2Params {
+	return argon2Params{time: m.cfg.Argon2.Time, memory: m.cfg.Argon2.MemoryKiB, threads: m.cfg.Argon2.Threads}
+}
+
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+// hashArgon2id encodes the hash in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func (m *Manager) hashArgon2id(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	p := m.argon2Params()
+	key := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, argon2KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func decodeArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return argon2Params{}, nil, nil, errors.New("malformed argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2Params{}, nil, nil, errors.New("unsupported argon2 version")
+	}
+
+	var p argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return argon2Params{}, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2Params{}, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return argon2Params{}, nil, nil, err
+	}
+	return p, salt, key, nil
+}
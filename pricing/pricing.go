@@ -0,0 +1,105 @@
+// Package pricing prices a cart: line totals, promotion discounts and tax.
+// Carts and the orders placed from them are priced the same way, in exact
+// minor units of one currency.
+package pricing
+
+import (
+	"fmt"
+
+	"ecommerce-backend/models"
+	"ecommerce-backend/money"
+	"ecommerce-backend/tax"
+)
+
+// Line is the price of one cart item. Total is what the customer pays for
+// it: Subtotal less Discount, plus Tax unless prices include tax.
+type Line struct {
+	UnitPrice money.Money
+	Subtotal  money.Money
+	Discount  money.Money
+	Tax       money.Money
+	Total     money.Money
+}
+
+// Quote is the price of a cart.
+type Quote struct {
+	Currency    string
+	Lines       []Line // indexed like the cart items
+	Subtotal    money.Money
+	Discount    money.Money
+	Tax         money.Money
+	Taxes       []tax.Amount
+	TaxIncluded bool
+	Total       money.Money
+}
+
+// Currency picks the currency a cart is priced in: the wanted one when
+// every item has a price in it, otherwise the base currency.
+func Currency(items []models.CartItem, want, base string) string {
+	for _, item := range items {
+		if _, ok := item.Product.PriceIn(want); !ok {
+			return base
+		}
+	}
+	return want
+}
+
+// UnitPrices returns each item's price in currency. It fails when an item
+// has no price there.
+func UnitPrices(items []models.CartItem, currency string) ([]money.Money, error) {
+	prices := make([]money.Money, len(items))
+	for i, item := range items {
+		price, ok := item.Product.PriceIn(currency)
+		if !ok {
+			return nil, fmt.Errorf("product %s has no price in %s", item.Product.ID.Hex(), currency)
+		}
+		prices[i] = price
+	}
+	return prices, nil
+}
+
+// Price prices items in currency for shipping to address. discounts holds
+// each item's promotion discount, indexed like items, and may be nil. Tax
+// is charged on the discounted amounts.
+func Price(items []models.CartItem, currency string, discounts []money.Money, taxes tax.Calculator, address tax.Address) (Quote, error) {
+	prices, err := UnitPrices(items, currency)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	zero := money.Zero(currency)
+	quote := Quote{
+		Currency: currency,
+		Lines:    make([]Line, len(items)),
+		Subtotal: zero,
+		Discount: zero,
+		Tax:      zero,
+		Total:    zero,
+	}
+	taxLines := make([]tax.Line, len(items))
+	for i, item := range items {
+		line := Line{UnitPrice: prices[i], Subtotal: prices[i].Mul(int64(item.Quantity)), Discount: zero}
+		if discounts != nil {
+			line.Discount = discounts[i]
+		}
+		quote.Lines[i] = line
+		taxLines[i] = tax.Line{Class: item.Product.TaxClass, Amount: line.Subtotal.Sub(line.Discount)}
+	}
+
+	taxed := taxes.Calculate(address, taxLines)
+	quote.Taxes = taxed.Taxes
+	quote.TaxIncluded = taxed.Included
+	for i := range quote.Lines {
+		line := &quote.Lines[i]
+		line.Tax = taxed.Lines[i].Tax
+		line.Total = taxLines[i].Amount
+		if !taxed.Included {
+			line.Total = line.Total.Add(line.Tax)
+		}
+		quote.Subtotal = quote.Subtotal.Add(line.Subtotal)
+		quote.Discount = quote.Discount.Add(line.Discount)
+		quote.Tax = quote.Tax.Add(line.Tax)
+		quote.Total = quote.Total.Add(line.Total)
+	}
+	return quote, nil
+}
@@ -0,0 +1,202 @@
+// Package promotions works out the discount a promotion gives a cart. It
+// has no storage of its own; callers load the promotion and cart lines and
+// check usage limits.
+package promotions
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"ecommerce-backend/models"
+	"ecommerce-backend/money"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RejectedError explains why a promotion does not apply to a cart. The
+// reason is shown to customers.
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return e.Reason
+}
+
+// Reasons a promotion does not apply.
+var (
+	ErrInactive        = &RejectedError{"this coupon is not active"}
+	ErrNotStarted      = &RejectedError{"this coupon is not valid yet"}
+	ErrExpired         = &RejectedError{"this coupon has expired"}
+	ErrUsedUp          = &RejectedError{"this coupon has been used up"}
+	ErrUsedByUser      = &RejectedError{"you have already used this coupon"}
+	ErrNoEligibleItems = &RejectedError{"no items in your cart qualify for this coupon"}
+)
+
+// Line is a cart line the promotion may discount.
+type Line struct {
+	ID        string
+	ProductID primitive.ObjectID
+	Category  string
+	UnitPrice money.Money
+	Quantity  int
+}
+
+// Total is the undiscounted price of the line.
+func (l Line) Total() money.Money {
+	return l.UnitPrice.Mul(int64(l.Quantity))
+}
+
+// Result is the outcome of applying a promotion to a cart.
+type Result struct {
+	// LineDiscounts holds the discount of each line, indexed like the lines
+	// passed to Apply. They add up to Discount.
+	LineDiscounts []money.Money
+	Discount      money.Money
+	FreeShipping  bool
+}
+
+// CheckAvailable reports whether the promotion can be used at now, given
+// how often the user has redeemed it.
+func CheckAvailable(p models.Promotion, now time.Time, userRedemptions int) error {
+	switch {
+	case !p.Active:
+		return ErrInactive
+	case p.StartsAt != nil && now.Before(*p.StartsAt):
+		return ErrNotStarted
+	case p.EndsAt != nil && !now.Before(*p.EndsAt):
+		return ErrExpired
+	case p.MaxUses > 0 && p.TimesUsed >= p.MaxUses:
+		return ErrUsedUp
+	case p.MaxUsesPerUser > 0 && userRedemptions >= p.MaxUsesPerUser:
+		return ErrUsedByUser
+	}
+	return nil
+}
+
+// InCurrency returns the promotion with its amounts in currency, so it can
+// be applied to a cart priced in that currency. Fixed amounts off must be
+// listed in the currency, since they are charged; the minimum order value
+// is only compared and is converted at the exchange rate.
+func InCurrency(p models.Promotion, currency string, rates money.Rates) (models.Promotion, error) {
+	if p.Type == models.PromotionFixedAmount {
+		amount, ok := p.AmountIn(currency)
+		if !ok {
+			return models.Promotion{}, &RejectedError{"this coupon can't be used with prices in " + currency}
+		}
+		p.Amount = amount
+	}
+	if p.MinOrderValue != nil {
+		minimum, ok := rates.Convert(*p.MinOrderValue, currency)
+		if !ok {
+			return models.Promotion{}, &RejectedError{"this coupon can't be used with prices in " + currency}
+		}
+		p.MinOrderValue = &minimum
+	}
+	return p, nil
+}
+
+// Apply works out the discount the promotion gives lines priced in
+// currency; see InCurrency for promotions with amounts in another one. It
+// checks the minimum order value and scope but not availability; see
+// CheckAvailable.
+func Apply(p models.Promotion, currency string, lines []Line) (Result, error) {
+	result := Result{LineDiscounts: make([]money.Money, len(lines)), Discount: money.Zero(currency)}
+	for i := range result.LineDiscounts {
+		result.LineDiscounts[i] = money.Zero(currency)
+	}
+
+	subtotal := money.Zero(currency)
+	var eligible []int
+	for i, line := range lines {
+		subtotal = subtotal.Add(line.Total())
+		if inScope(p, line) {
+			eligible = append(eligible, i)
+		}
+	}
+	if p.MinOrderValue != nil && subtotal.Cmp(*p.MinOrderValue) < 0 {
+		return Result{}, &RejectedError{"the order must be at least " + p.MinOrderValue.String() + " to use this coupon"}
+	}
+	if len(eligible) == 0 {
+		return Result{}, ErrNoEligibleItems
+	}
+
+	switch p.Type {
+	case models.PromotionPercentage:
+		basisPoints := int64(math.Round(min(p.Value, 100) * 100))
+		for _, i := range eligible {
+			result.LineDiscounts[i] = lines[i].Total().MulRatio(basisPoints, 10000)
+		}
+	case models.PromotionFixedAmount:
+		allocate(result.LineDiscounts, lines, eligible, p.Amount)
+	case models.PromotionBuyXGetY:
+		buyXGetY(result.LineDiscounts, lines, eligible, p.BuyQuantity, p.GetQuantity)
+	case models.PromotionFreeShipping:
+		result.FreeShipping = true
+	default:
+		return Result{}, fmt.Errorf("unknown promotion type %q", p.Type)
+	}
+
+	for _, discount := range result.LineDiscounts {
+		result.Discount = result.Discount.Add(discount)
+	}
+	return result, nil
+}
+
+func inScope(p models.Promotion, line Line) bool {
+	if len(p.ProductIDs) == 0 && len(p.Categories) == 0 {
+		return true
+	}
+	return slices.Contains(p.ProductIDs, line.ProductID) || slices.Contains(p.Categories, line.Category)
+}
+
+// allocate spreads a fixed amount over the eligible lines in proportion to
+// their totals, capped at what they cost.
+func allocate(discounts []money.Money, lines []Line, eligible []int, amount money.Money) {
+	eligibleTotal := money.Zero(amount.Currency)
+	weights := make([]int64, len(eligible))
+	for n, i := range eligible {
+		eligibleTotal = eligibleTotal.Add(lines[i].Total())
+		weights[n] = lines[i].Total().Amount
+	}
+	for n, share := range amount.Min(eligibleTotal).Allocate(weights) {
+		discounts[eligible[n]] = share
+	}
+}
+
+// buyXGetY makes the cheapest get units free in every group of buy+get
+// eligible units, taking units from the most expensive down so customers
+// get the usual "cheapest item free" deal. Units are counted per line, not
+// listed one by one, so large quantities cost nothing extra.
+func buyXGetY(discounts []money.Money, lines []Line, eligible []int, buy, get int) {
+	if buy < 1 || get < 1 {
+		return
+	}
+
+	order := slices.Clone(eligible)
+	slices.SortStableFunc(order, func(a, b int) int { return lines[b].UnitPrice.Cmp(lines[a].UnitPrice) })
+
+	var units int64
+	for _, i := range order {
+		units += int64(lines[i].Quantity)
+	}
+	group := int64(buy + get)
+	grouped := units / group * group
+
+	// free returns how many of the first n units, in price order, are free.
+	free := func(n int64) int64 {
+		n = min(n, grouped)
+		return n/group*int64(get) + max(0, n%group-int64(buy))
+	}
+
+	var start int64
+	for _, i := range order {
+		end := start + int64(lines[i].Quantity)
+		if count := free(end) - free(start); count > 0 {
+			discounts[i] = discounts[i].Add(lines[i].UnitPrice.Mul(count))
+		}
+		start = end
+	}
+}
@@ -0,0 +1,272 @@
+package promotions
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"ecommerce-backend/models"
+	"ecommerce-backend/money"
+)
+
+func usd(amount int64) money.Money {
+	return money.New(amount, "USD")
+}
+
+// lines builds cart lines from unit price, quantity pairs.
+func lines(pairs ...int64) []Line {
+	var out []Line
+	for i := 0; i < len(pairs); i += 2 {
+		out = append(out, Line{UnitPrice: usd(pairs[i]), Quantity: int(pairs[i+1])})
+	}
+	return out
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+		lines     []Line
+		want      []int64 // discount per line
+	}{
+		{
+			name:      "percentage rounds each line",
+			promotion: models.Promotion{Type: models.PromotionPercentage, Value: 15},
+			lines:     lines(999, 1, 333, 1),
+			want:      []int64{150, 50},
+		},
+		{
+			name:      "percentage capped at 100",
+			promotion: models.Promotion{Type: models.PromotionPercentage, Value: 150},
+			lines:     lines(999, 2),
+			want:      []int64{1998},
+		},
+		{
+			name:      "fixed amount in proportion to line totals",
+			promotion: models.Promotion{Type: models.PromotionFixedAmount, Amount: usd(1000)},
+			lines:     lines(3000, 1, 1000, 2),
+			want:      []int64{600, 400},
+		},
+		{
+			name:      "fixed amount remainder to the first lines",
+			promotion: models.Promotion{Type: models.PromotionFixedAmount, Amount: usd(1000)},
+			lines:     lines(1000, 1, 1000, 1, 1000, 1),
+			want:      []int64{334, 333, 333},
+		},
+		{
+			name:      "fixed amount capped at the eligible total",
+			promotion: models.Promotion{Type: models.PromotionFixedAmount, Amount: usd(5000)},
+			lines:     lines(1000, 1, 250, 2),
+			want:      []int64{1000, 500},
+		},
+		{
+			name:      "buy 2 get 1: cheapest free",
+			promotion: models.Promotion{Type: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1},
+			lines:     lines(2000, 1, 1000, 1, 3000, 1),
+			want:      []int64{0, 1000, 0},
+		},
+		{
+			name:      "buy 2 get 1: one line, several groups",
+			promotion: models.Promotion{Type: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1},
+			lines:     lines(1000, 7),
+			want:      []int64{2000},
+		},
+		{
+			name:      "buy 2 get 1: units outside a full group pay",
+			promotion: models.Promotion{Type: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1},
+			lines:     lines(1000, 2, 3000, 2),
+			want:      []int64{1000, 0},
+		},
+		{
+			name:      "buy 1 get 1: groups from the most expensive down",
+			promotion: models.Promotion{Type: models.PromotionBuyXGetY, BuyQuantity: 1, GetQuantity: 1},
+			lines:     lines(1000, 1, 400, 1, 600, 1, 800, 1),
+			want:      []int64{0, 400, 0, 800},
+		},
+		{
+			name:      "buy 2 get 2: a group split across lines",
+			promotion: models.Promotion{Type: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 2},
+			lines:     lines(500, 3, 900, 1),
+			want:      []int64{1000, 0},
+		},
+		{
+			name:      "buy 2 get 1: too few units",
+			promotion: models.Promotion{Type: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1},
+			lines:     lines(1000, 1, 500, 1),
+			want:      []int64{0, 0},
+		},
+		{
+			name:      "free shipping",
+			promotion: models.Promotion{Type: models.PromotionFreeShipping},
+			lines:     lines(1000, 1),
+			want:      []int64{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Apply(tt.promotion, "USD", tt.lines)
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+
+			got := make([]int64, len(result.LineDiscounts))
+			var total int64
+			for i, discount := range result.LineDiscounts {
+				got[i] = discount.Amount
+				total += discount.Amount
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("line discounts = %v, want %v", got, tt.want)
+			}
+			if result.Discount != usd(total) {
+				t.Errorf("discount = %v, lines add up to %d", result.Discount, total)
+			}
+			if result.FreeShipping != (tt.promotion.Type == models.PromotionFreeShipping) {
+				t.Errorf("free shipping = %v", result.FreeShipping)
+			}
+		})
+	}
+}
+
+func TestApplyScope(t *testing.T) {
+	shoes := Line{Category: "shoes", UnitPrice: usd(1000), Quantity: 3}
+	hat := Line{Category: "hats", UnitPrice: usd(200), Quantity: 1}
+
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+		want      []int64
+	}{
+		{
+			name:      "fixed amount",
+			promotion: models.Promotion{Type: models.PromotionFixedAmount, Amount: usd(500)},
+			want:      []int64{500, 0},
+		},
+		{
+			name:      "buy 2 get 1 counts only units in scope",
+			promotion: models.Promotion{Type: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1},
+			want:      []int64{1000, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.promotion.Categories = []string{"shoes"}
+			result, err := Apply(tt.promotion, "USD", []Line{shoes, hat})
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			got := []int64{result.LineDiscounts[0].Amount, result.LineDiscounts[1].Amount}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("line discounts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRejections(t *testing.T) {
+	minimum := usd(5000)
+
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+		lines     []Line
+		wantErr   bool
+	}{
+		{
+			name:      "below the minimum order",
+			promotion: models.Promotion{Type: models.PromotionPercentage, Value: 10, MinOrderValue: &minimum},
+			lines:     lines(2000, 2, 999, 1),
+			wantErr:   true,
+		},
+		{
+			name:      "at the minimum order",
+			promotion: models.Promotion{Type: models.PromotionPercentage, Value: 10, MinOrderValue: &minimum},
+			lines:     lines(2000, 2, 1000, 1),
+		},
+		{
+			// The minimum is on the whole cart, not just the items in scope
+			name:      "minimum counts items out of scope",
+			promotion: models.Promotion{Type: models.PromotionPercentage, Value: 10, MinOrderValue: &minimum, Categories: []string{"shoes"}},
+			lines:     []Line{{Category: "shoes", UnitPrice: usd(1000), Quantity: 1}, {Category: "hats", UnitPrice: usd(4000), Quantity: 1}},
+		},
+		{
+			name:      "no items in scope",
+			promotion: models.Promotion{Type: models.PromotionPercentage, Value: 10, Categories: []string{"shoes"}},
+			lines:     []Line{{Category: "hats", UnitPrice: usd(4000), Quantity: 1}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Apply(tt.promotion, "USD", tt.lines)
+			var rejected *RejectedError
+			if tt.wantErr && !errors.As(err, &rejected) {
+				t.Fatalf("Apply error = %v, want a RejectedError", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+		})
+	}
+}
+
+func TestInCurrency(t *testing.T) {
+	rates := money.Rates{Base: "USD", Rates: map[string]float64{"EUR": 0.92, "GBP": 0.8}}
+	minimum := usd(5000)
+	fixed := models.Promotion{
+		Type:    models.PromotionFixedAmount,
+		Amount:  usd(1000),
+		Amounts: []money.Money{money.New(900, "EUR")},
+	}
+	percentage := models.Promotion{Type: models.PromotionPercentage, Value: 10, MinOrderValue: &minimum}
+
+	tests := []struct {
+		name        string
+		promotion   models.Promotion
+		currency    string
+		rates       money.Rates
+		wantAmount  money.Money
+		wantMinimum *money.Money
+		wantErr     bool
+	}{
+		{name: "fixed amount in its currency", promotion: fixed, currency: "USD", rates: rates, wantAmount: usd(1000)},
+		{name: "fixed amount listed in another currency", promotion: fixed, currency: "EUR", rates: rates, wantAmount: money.New(900, "EUR")},
+		{name: "fixed amount not listed in the currency", promotion: fixed, currency: "GBP", rates: rates, wantErr: true},
+		{
+			name:        "minimum converted at the rate",
+			promotion:   percentage,
+			currency:    "GBP",
+			rates:       rates,
+			wantMinimum: &money.Money{Amount: 4000, Currency: "GBP"},
+		},
+		{name: "no rate for the minimum", promotion: percentage, currency: "JPY", rates: rates, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InCurrency(tt.promotion, tt.currency, tt.rates)
+			if tt.wantErr {
+				var rejected *RejectedError
+				if !errors.As(err, &rejected) {
+					t.Fatalf("InCurrency error = %v, want a RejectedError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InCurrency: %v", err)
+			}
+			if tt.promotion.Type == models.PromotionFixedAmount && got.Amount != tt.wantAmount {
+				t.Errorf("amount = %v, want %v", got.Amount, tt.wantAmount)
+			}
+			if tt.wantMinimum != nil && (got.MinOrderValue == nil || *got.MinOrderValue != *tt.wantMinimum) {
+				t.Errorf("minimum = %v, want %v", got.MinOrderValue, *tt.wantMinimum)
+			}
+		})
+	}
+	if *percentage.MinOrderValue != usd(5000) {
+		t.Errorf("InCurrency changed the promotion passed in: minimum %v", percentage.MinOrderValue)
+	}
+}
@@ -93,7 +93,7 @@ if (document.getElementById('products-container')) {
     // Fetch products from API and initial display
     async function fetchAndDisplayProducts() {
         try {
-            const response = await fetch('/api/products');
+            const response = await fetch('/api/v1/products');
             if (!response.ok) {
                 throw new Error(`HTTP error! status: ${response.status}`);
             }
@@ -116,7 +116,7 @@ if (document.getElementById('product-details')) {
     
     async function fetchProductDetails() {
         try {
-            const response = await fetch(`/api/products/${productId}`);
+            const response = await fetch(`/api/v1/products/${productId}`);
             if (!response.ok) {
                 let errorMessage = 'Error loading product details.';
                 if (response.status === 404) {
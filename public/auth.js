@@ -0,0 +1,189 @@
+// Sign-in and sign-out for the storefront. The session token lives in an
+// HttpOnly cookie that scripts can't read; they only handle the CSRF token,
+// which the API requires on every state-changing request made with the
+// session cookie.
+
+async function getCsrfToken() {
+    const response = await fetch('/api/auth/csrf', { credentials: 'same-origin' });
+    if (!response.ok) {
+        throw new Error(`HTTP error! status: ${response.status}`);
+    }
+    const data = await response.json();
+    return data.csrf_token;
+}
+
+async function postJSON(url, body) {
+    const csrfToken = await getCsrfToken();
+    const response = await fetch(url, {
+        method: 'POST',
+        credentials: 'same-origin',
+        headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': csrfToken },
+        body: JSON.stringify(body),
+    });
+    const data = await response.json().catch(() => ({}));
+    return { ok: response.ok, status: response.status, data };
+}
+
+function showError(element, message) {
+    element.textContent = message;
+    element.hidden = false;
+}
+
+// Handle sign-in page
+if (document.getElementById('login-form')) {
+    const form = document.getElementById('login-form');
+    const codeField = document.getElementById('code-field');
+    const codeInput = document.getElementById('code');
+    const errorElement = document.getElementById('login-error');
+    let challengeToken = null;
+
+    // A social login of an account with two-factor authentication lands
+    // here with its challenge in the fragment; only the code is left to
+    // enter
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    if (params.has('challenge')) {
+        challengeToken = params.get('challenge');
+        window.history.replaceState(null, '', window.location.pathname);
+        for (const name of ['email', 'password']) {
+            form[name].disabled = true;
+            form[name].hidden = true;
+            form.querySelector(`label[for="${name}"]`).hidden = true;
+        }
+        codeField.hidden = false;
+        codeInput.focus();
+    }
+
+    form.addEventListener('submit', async (e) => {
+        e.preventDefault();
+        errorElement.hidden = true;
+
+        try {
+            let result;
+            if (challengeToken) {
+                // Second step for accounts with two-factor authentication
+                const code = codeInput.value.trim();
+                const body = { challenge_token: challengeToken, mode: 'cookie' };
+                if (/^\d{6}$/.test(code)) {
+                    body.code = code;
+                } else {
+                    body.recovery_code = code;
+                }
+                result = await postJSON('/api/auth/2fa/verify', body);
+            } else {
+                result = await postJSON('/api/auth/login', {
+                    email: form.email.value,
+                    password: form.password.value,
+                    mode: 'cookie',
+                });
+            }
+
+            if (result.ok && result.data.two_factor_required) {
+                challengeToken = result.data.challenge_token;
+                codeField.hidden = false;
+                codeInput.focus();
+                return;
+            }
+            if (!result.ok) {
+                showError(errorElement, result.data.error || 'Sign-in failed. Please try again.');
+                return;
+            }
+            window.location.href = '/products';
+        } catch (error) {
+            console.error('Sign-in failed:', error);
+            showError(errorElement, 'Sign-in failed. Please try again later.');
+        }
+    });
+}
+
+// Handle sign-out page
+if (document.getElementById('logout-button')) {
+    const button = document.getElementById('logout-button');
+    const status = document.getElementById('logout-status');
+    const errorElement = document.getElementById('logout-error');
+
+    button.addEventListener('click', async () => {
+        errorElement.hidden = true;
+        try {
+            const result = await postJSON('/api/auth/logout', {});
+            if (!result.ok) {
+                showError(errorElement, result.data.error || 'Sign-out failed. Please try again.');
+                return;
+            }
+            status.textContent = 'You have been signed out.';
+            button.hidden = true;
+        } catch (error) {
+            console.error('Sign-out failed:', error);
+            showError(errorElement, 'Sign-out failed. Please try again later.');
+        }
+    });
+}
+
+// takeLinkToken reads the token from an emailed link and drops it from the
+// address bar, so it doesn't linger in history or leak in the Referer.
+function takeLinkToken() {
+    const token = new URLSearchParams(window.location.search).get('token');
+    window.history.replaceState(null, '', window.location.pathname);
+    return token;
+}
+
+// Handle the email verification link
+if (document.getElementById('verify-status')) {
+    const status = document.getElementById('verify-status');
+    const errorElement = document.getElementById('verify-error');
+    const token = takeLinkToken();
+
+    (async () => {
+        if (!token) {
+            status.hidden = true;
+            showError(errorElement, 'This verification link is incomplete. Please use the link from your email.');
+            return;
+        }
+        try {
+            const result = await postJSON('/api/auth/verify-email', { token });
+            if (!result.ok) {
+                status.hidden = true;
+                showError(errorElement, result.data.error || 'Verification failed. Please try again.');
+                return;
+            }
+            status.innerHTML = 'Your email address has been verified. <a href="/login">Sign in</a>';
+        } catch (error) {
+            console.error('Verification failed:', error);
+            status.hidden = true;
+            showError(errorElement, 'Verification failed. Please try again later.');
+        }
+    })();
+}
+
+// Handle the password reset link
+if (document.getElementById('reset-form')) {
+    const form = document.getElementById('reset-form');
+    const status = document.getElementById('reset-status');
+    const errorElement = document.getElementById('reset-error');
+    const token = takeLinkToken();
+
+    if (!token) {
+        showError(errorElement, 'This reset link is incomplete. Please use the link from your email.');
+    }
+
+    form.addEventListener('submit', async (e) => {
+        e.preventDefault();
+        errorElement.hidden = true;
+
+        if (form.password.value !== form.confirm.value) {
+            showError(errorElement, 'The passwords do not match.');
+            return;
+        }
+        try {
+            const result = await postJSON('/api/auth/reset-password', { token, password: form.password.value });
+            if (!result.ok) {
+                showError(errorElement, result.data.error || 'Password reset failed. Please try again.');
+                return;
+            }
+            form.hidden = true;
+            status.hidden = false;
+        } catch (error) {
+            console.error('Password reset failed:', error);
+            showError(errorElement, 'Password reset failed. Please try again later.');
+        }
+    });
+}
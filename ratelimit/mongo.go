@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"ecommerce-backend/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore keeps buckets in the rate_limits collection so limits are
+// shared by every instance. Each take is a single atomic pipeline update;
+// a TTL index on expires_at removes buckets once they have refilled.
+type MongoStore struct{}
+
+func NewMongoStore() *MongoStore {
+	return &MongoStore{}
+}
+
+func (s *MongoStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+	burst := float64(limit.Burst)
+
+	// Refill from the elapsed time, then take a token if one is available
+	refilled := bson.M{"$min": bson.A{
+		burst,
+		bson.M{"$add": bson.A{
+			bson.M{"$ifNull": bson.A{"$tokens", burst}},
+			bson.M{"$multiply": bson.A{
+				bson.M{"$divide": bson.A{
+					bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updated_at", now}}}},
+					1000,
+				}},
+				limit.Rate,
+			}},
+		}},
+	}}
+	pipeline := bson.A{
+		bson.M{"$set": bson.M{"tokens": refilled, "updated_at": now}},
+		bson.M{"$set": bson.M{
+			"allowed": bson.M{"$gte": bson.A{"$tokens", 1}},
+			"tokens": bson.M{"$cond": bson.A{
+				bson.M{"$gte": bson.A{"$tokens", 1}},
+				bson.M{"$subtract": bson.A{"$tokens", 1}},
+				"$tokens",
+			}},
+		}},
+		bson.M{"$set": bson.M{"expires_at": bson.M{"$add": bson.A{
+			now,
+			bson.M{"$multiply": bson.A{
+				bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{burst, "$tokens"}}, limit.Rate}},
+				1000,
+			}},
+		}}}},
+	}
+
+	var doc struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+	err := config.GetCollection("rate_limits").FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": key},
+		pipeline,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&doc)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result(doc.Allowed, doc.Tokens, limit), nil
+}
+
+// Prune is a no-op; the TTL index expires buckets.
+func (s *MongoStore) Prune(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"ecommerce-backend/config"
+)
+
+// Limit describes a token bucket: Rate tokens are added per second up to
+// Burst, and every request takes one token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Every returns a limit allowing n requests per period, with bursts of up
+// to burst requests. A burst of zero means n.
+func Every(n int, period time.Duration, burst int) Limit {
+	if burst <= 0 {
+		burst = n
+	}
+	return Limit{Rate: float64(n) / period.Seconds(), Burst: burst}
+}
+
+// FromConfig converts a configured limit to a token bucket.
+func FromConfig(cfg config.LimitConfig) Limit {
+	return Every(cfg.Requests, cfg.Period, cfg.Burst)
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// Store keeps token buckets. Implementations must be safe for concurrent
+// use; the Mongo store also shares buckets between instances.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Prune drops buckets that have refilled completely.
+	Prune(ctx context.Context) error
+}
+
+// NewStore returns the store named by the configuration: "mongo" or
+// "memory".
+func NewStore(kind string) Store {
+	if kind == "mongo" {
+		return NewMongoStore()
+	}
+	return NewMemoryStore()
+}
+
+// result derives the response for a bucket holding tokens after the
+// request has been counted.
+func result(allowed bool, tokens float64, limit Limit) Result {
+	r := Result{
+		Allowed:    allowed,
+		Limit:      limit.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	if s < 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// refill returns the token count of a bucket last updated at updated,
+// topped up for the time elapsed until now.
+func refill(tokens float64, updated, now time.Time, limit Limit) float64 {
+	tokens += now.Sub(updated).Seconds() * limit.Rate
+	return math.Min(tokens, float64(limit.Burst))
+}
@@ -0,0 +1,245 @@
+package routes
+
+import (
+	"net/http"
+
+	"ecommerce-backend/dto"
+	"ecommerce-backend/openapi"
+	"ecommerce-backend/tokens"
+)
+
+var apiInfo = openapi.Info{Title: "Gommerce API", Version: "2.0.0"}
+
+// Docs returns the documentation of every route registered by
+// SetupRoutes. Each new route needs an entry here; `go run . openapi check`
+// fails while any route is undocumented.
+func Docs() *openapi.Registry {
+	docs := openapi.NewRegistry()
+	docs.Alias("/api/", "/api/v2/")
+	docs.Ignore(storefrontPaths...)
+
+	// Operational endpoints
+	docs.Add(http.MethodGet, "/healthz", openapi.Operation{
+		Summary: "Liveness probe", Tags: []string{"health"}, Response: dto.HealthResponse{},
+	})
+	docs.Add(http.MethodGet, "/readyz", openapi.Operation{
+		Summary: "Readiness probe with per-dependency checks", Tags: []string{"health"}, Response: dto.ReadinessResponse{},
+	})
+	docs.Add(http.MethodGet, "/.well-known/jwks.json", openapi.Operation{
+		Summary: "Public keys that verify access tokens", Tags: []string{"auth"}, Response: tokens.JWKS{},
+	})
+	docs.Add(http.MethodGet, "/metrics", openapi.Operation{
+		Summary: "Prometheus metrics", Tags: []string{"health"}, Response: "", ContentType: "text/plain",
+	})
+	docs.Add(http.MethodGet, "/api/openapi.json", openapi.Operation{
+		Summary: "OpenAPI document", Tags: []string{"docs"}, Response: map[string]any{},
+	})
+	docs.Add(http.MethodGet, "/api/docs", openapi.Operation{
+		Summary: "Swagger UI", Tags: []string{"docs"}, Response: "", ContentType: "text/html",
+	})
+
+	// v1
+	docs.Add(http.MethodGet, "/api/v1/products", openapi.Operation{
+		Summary: "List products (legacy storefront format)", Tags: []string{"v1"},
+		Query: dto.ProductQuery{}, Response: []dto.ProductV1{},
+	})
+	docs.Add(http.MethodGet, "/api/v1/products/:id", openapi.Operation{
+		Summary: "Get a product by integer id (legacy storefront format)", Tags: []string{"v1"},
+		Response: dto.ProductV1{},
+	})
+
+	// v2 auth
+	docs.Add(http.MethodPost, "/api/v2/auth/register", openapi.Operation{
+		Summary: "Register a new user", Tags: []string{"auth"},
+		Request: dto.RegisterRequest{}, Response: dto.AuthResponse{}, Status: http.StatusCreated,
+	})
+	docs.Add(http.MethodPost, "/api/v2/auth/login", openapi.Operation{
+		Summary: "Log in with email and password (returns a two-factor challenge instead of a token when enabled; mode \"cookie\" sets a session cookie and needs the CSRF header)",
+		Tags:    []string{"auth"},
+		Request: dto.LoginRequest{}, Response: dto.AuthResponse{},
+	})
+	docs.Add(http.MethodPost, "/api/v2/auth/2fa/verify", openapi.Operation{
+		Summary: "Complete a two-factor login with a TOTP or recovery code", Tags: []string{"auth"},
+		Request: dto.TwoFactorLoginRequest{}, Response: dto.AuthResponse{},
+	})
+
+	docs.Add(http.MethodPost, "/api/v2/auth/verify-email", openapi.Operation{
+		Summary: "Verify an email address with an emailed token", Tags: []string{"auth"},
+		Request: dto.VerifyEmailRequest{}, Response: dto.MessageResponse{},
+	})
+	docs.Add(http.MethodPost, "/api/v2/auth/forgot-password", openapi.Operation{
+		Summary: "Email a password reset link", Tags: []string{"auth"},
+		Request: dto.ForgotPasswordRequest{}, Response: dto.MessageResponse{},
+	})
+	docs.Add(http.MethodPost, "/api/v2/auth/reset-password", openapi.Operation{
+		Summary: "Set a new password with an emailed token", Tags: []string{"auth"},
+		Request: dto.ResetPasswordRequest{}, Response: dto.MessageResponse{},
+	})
+
+	docs.Add(http.MethodPost, "/api/v2/auth/logout", openapi.Operation{
+		Summary: "Clear the session cookie", Tags: []string{"auth"},
+		Response: dto.MessageResponse{},
+	})
+	docs.Add(http.MethodGet, "/api/v2/auth/csrf", openapi.Operation{
+		Summary: "Get the CSRF token cookie-authenticated browsers send with writes", Tags: []string{"auth"},
+		Response: dto.CSRFTokenResponse{},
+	})
+	docs.Add(http.MethodGet, "/api/v2/auth/oidc", openapi.Operation{
+		Summary: "List the configured identity providers", Tags: []string{"auth"},
+		Response: dto.OIDCProvidersResponse{},
+	})
+	docs.Add(http.MethodGet, "/api/v2/auth/oidc/:provider/login", openapi.Operation{
+		Summary: "Start a social login; redirects to the identity provider", Tags: []string{"auth"},
+		Query: dto.OIDCLoginQuery{}, Response: "", ContentType: "text/html", Status: http.StatusFound,
+	})
+	docs.Add(http.MethodGet, "/api/v2/auth/oidc/:provider/callback", openapi.Operation{
+		Summary: "Complete a social login (token mode; cookie mode sets the session cookie and redirects to the storefront)", Tags: []string{"auth"},
+		Query: dto.OIDCCallbackQuery{}, Response: dto.AuthResponse{},
+	})
+
+	// v2 products
+	docs.Add(http.MethodGet, "/api/v2/products", openapi.Operation{
+		Summary: "List products", Tags: []string{"products"},
+		Query: dto.ProductQuery{}, Response: dto.ProductListV2{},
+	})
+	docs.Add(http.MethodGet, "/api/v2/products/:id", openapi.Operation{
+		Summary: "Get a product", Tags: []string{"products"},
+		Query: dto.CurrencyQuery{}, Response: dto.ProductV2{},
+	})
+	docs.Add(http.MethodPost, "/api/v2/products", openapi.Operation{
+		Summary: "Create a product (staff or products:write API key)", Tags: []string{"products"}, Auth: true,
+		Request: dto.ProductRequest{}, Response: dto.ProductV2{}, Status: http.StatusCreated,
+	})
+	docs.Add(http.MethodPut, "/api/v2/products/:id", openapi.Operation{
+		Summary: "Update a product (staff or products:write API key)", Tags: []string{"products"}, Auth: true,
+		Request: dto.ProductRequest{}, Response: dto.MessageResponse{},
+	})
+	docs.Add(http.MethodDelete, "/api/v2/products/:id", openapi.Operation{
+		Summary: "Delete a product (staff or products:write API key)", Tags: []string{"products"}, Auth: true,
+		Response: dto.MessageResponse{},
+	})
+
+	// v2 exchange rates
+	docs.Add(http.MethodGet, "/api/v2/exchange-rates", openapi.Operation{
+		Summary: "List exchange rates from the base currency", Tags: []string{"products"},
+		Response: dto.ExchangeRatesResponse{},
+	})
+
+	// v2 account
+	docs.Add(http.MethodGet, "/api/v2/me", openapi.Operation{
+		Summary: "Get the current user's profile", Tags: []string{"account"}, Auth: true,
+		Response: dto.User{},
+	})
+	docs.Add(http.MethodPatch, "/api/v2/me", openapi.Operation{
+		Summary: "Update name or start an email change", Tags: []string{"account"}, Auth: true,
+		Request: dto.UpdateProfileRequest{}, Response: dto.User{},
+	})
+	docs.Add(http.MethodDelete, "/api/v2/me", openapi.Operation{
+		Summary: "Delete and anonymize the current account", Tags: []string{"account"}, Auth: true,
+		Request: dto.DeleteAccountRequest{}, Response: dto.MessageResponse{},
+	})
+	docs.Add(http.MethodPost, "/api/v2/me/password", openapi.Operation{
+		Summary: "Change password and sign out other sessions", Tags: []string{"account"}, Auth: true,
+		Request: dto.ChangePasswordRequest{}, Response: dto.TokenResponse{},
+	})
+	docs.Add(http.MethodGet, "/api/v2/me/security-events", openapi.Operation{
+		Summary: "List recent sign-in attempts for the current user", Tags: []string{"account"}, Auth: true,
+		Response: dto.SecurityEventsResponse{},
+	})
+	docs.Add(http.MethodPost, "/api/v2/me/2fa/setup", openapi.Operation{
+		Summary: "Start TOTP enrollment and get an otpauth URI", Tags: []string{"account"}, Auth: true,
+		Response: dto.TwoFactorSetupResponse{},
+	})
+	docs.Add(http.MethodPost, "/api/v2/me/2fa/confirm", openapi.Operation{
+		Summary: "Confirm TOTP enrollment with a code and get recovery codes", Tags: []string{"account"}, Auth: true,
+		Request: dto.TwoFactorConfirmRequest{}, Response: dto.RecoveryCodesResponse{},
+	})
+	docs.Add(http.MethodPost, "/api/v2/me/2fa/disable", openapi.Operation{
+		Summary: "Disable two-factor authentication", Tags: []string{"account"}, Auth: true,
+		Request: dto.TwoFactorDisableRequest{}, Response: dto.MessageResponse{},
+	})
+
+	// v2 cart
+	docs.Add(http.MethodPost, "/api/v2/cart", openapi.Operation{
+		Summary: "Add an item to the cart", Tags: []string{"cart"}, Auth: true,
+		Request: dto.AddToCartRequest{}, Response: dto.CartEntry{}, Status: http.StatusCreated,
+	})
+	docs.Add(http.MethodGet, "/api/v2/cart", openapi.Operation{
+		Summary: "Get the cart with product details, discounts, tax and totals", Tags: []string{"cart"}, Auth: true,
+		Query: dto.CartQuery{}, Response: dto.CartResponse{},
+	})
+	docs.Add(http.MethodDelete, "/api/v2/cart/:id", openapi.Operation{
+		Summary: "Remove an item from the cart", Tags: []string{"cart"}, Auth: true,
+		Response: dto.MessageResponse{},
+	})
+	docs.Add(http.MethodGet, "/api/v2/cart/shipping-options", openapi.Operation{
+		Summary: "Quote the shipping methods available for the cart", Tags: []string{"cart"}, Auth: true,
+		Query: dto.CartQuery{}, Response: dto.ShippingOptionsResponse{},
+	})
+	docs.Add(http.MethodPost, "/api/v2/cart/coupon", openapi.Operation{
+		Summary: "Apply a coupon code to the cart (422 with the reason when it does not apply)", Tags: []string{"cart"}, Auth: true,
+		Query: dto.CartQuery{}, Request: dto.ApplyCouponRequest{}, Response: dto.CartResponse{},
+	})
+	docs.Add(http.MethodDelete, "/api/v2/cart/coupon", openapi.Operation{
+		Summary: "Remove the coupon from the cart", Tags: []string{"cart"}, Auth: true,
+		Query: dto.CartQuery{}, Response: dto.CartResponse{},
+	})
+
+	// v2 orders
+	docs.Add(http.MethodPost, "/api/v2/orders", openapi.Operation{
+		Summary: "Place an order for the cart, taxed for the shipping address, shipped by the chosen method and charged in the requested currency when every item is priced in it", Tags: []string{"orders"}, Auth: true,
+		Request: dto.CheckoutRequest{}, Response: dto.Order{}, Status: http.StatusCreated,
+	})
+	docs.Add(http.MethodGet, "/api/v2/orders", openapi.Operation{
+		Summary: "List the current user's orders", Tags: []string{"orders"}, Auth: true,
+		Response: dto.OrdersResponse{},
+	})
+	docs.Add(http.MethodGet, "/api/v2/orders/:id", openapi.Operation{
+		Summary: "Get one of the current user's orders", Tags: []string{"orders"}, Auth: true,
+		Response: dto.Order{},
+	})
+
+	// v2 admin
+	docs.Add(http.MethodGet, "/api/v2/admin/orders", openapi.Operation{
+		Summary: "List every customer's orders (staff or orders:read API key)", Tags: []string{"admin"}, Auth: true,
+		Query: dto.AdminOrdersQuery{}, Response: dto.AdminOrdersResponse{},
+	})
+	docs.Add(http.MethodGet, "/api/v2/admin/api-keys", openapi.Operation{
+		Summary: "List API keys (admin)", Tags: []string{"admin"}, Auth: true,
+		Response: dto.APIKeysResponse{},
+	})
+	docs.Add(http.MethodPost, "/api/v2/admin/api-keys", openapi.Operation{
+		Summary: "Create a scoped API key; the key is only returned once (admin)", Tags: []string{"admin"}, Auth: true,
+		Request: dto.CreateAPIKeyRequest{}, Response: dto.CreatedAPIKeyResponse{}, Status: http.StatusCreated,
+	})
+	docs.Add(http.MethodDelete, "/api/v2/admin/api-keys/:id", openapi.Operation{
+		Summary: "Revoke an API key (admin)", Tags: []string{"admin"}, Auth: true,
+		Response: dto.MessageResponse{},
+	})
+	docs.Add(http.MethodGet, "/api/v2/admin/promotions", openapi.Operation{
+		Summary: "List promotions (admin)", Tags: []string{"admin"}, Auth: true,
+		Response: dto.PromotionsResponse{},
+	})
+	docs.Add(http.MethodPost, "/api/v2/admin/promotions", openapi.Operation{
+		Summary: "Create a promotion (admin)", Tags: []string{"admin"}, Auth: true,
+		Request: dto.PromotionRequest{}, Response: dto.Promotion{}, Status: http.StatusCreated,
+	})
+	docs.Add(http.MethodPut, "/api/v2/admin/promotions/:id", openapi.Operation{
+		Summary: "Update a promotion (admin)", Tags: []string{"admin"}, Auth: true,
+		Request: dto.PromotionRequest{}, Response: dto.Promotion{},
+	})
+	docs.Add(http.MethodDelete, "/api/v2/admin/promotions/:id", openapi.Operation{
+		Summary: "Delete a promotion (admin)", Tags: []string{"admin"}, Auth: true,
+		Response: dto.MessageResponse{},
+	})
+	docs.Add(http.MethodPut, "/api/v2/admin/exchange-rates/:currency", openapi.Operation{
+		Summary: "Set the exchange rate of a currency (admin)", Tags: []string{"admin"}, Auth: true,
+		Request: dto.ExchangeRateRequest{}, Response: dto.ExchangeRate{},
+	})
+	docs.Add(http.MethodDelete, "/api/v2/admin/exchange-rates/:currency", openapi.Operation{
+		Summary: "Delete the exchange rate of a currency (admin)", Tags: []string{"admin"}, Auth: true,
+		Response: dto.MessageResponse{},
+	})
+
+	return docs
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"testing"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/jobs"
+	"ecommerce-backend/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRoutesAreDocumented fails when a route is registered without an
+// OpenAPI entry in Docs.
+func TestRoutesAreDocumented(t *testing.T) {
+	cfg := config.Default()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	SetupRoutes(router, cfg, Dependencies{
+		Jobs:       jobs.NewManager(),
+		RateLimits: ratelimit.NewMemoryStore(),
+	})
+
+	for _, route := range Docs().Undocumented(router.Routes()) {
+		t.Errorf("undocumented route: %s", route)
+	}
+}
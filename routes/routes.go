@@ -1,46 +1,231 @@
 package routes
 
 import (
+	"ecommerce-backend/config"
 	"ecommerce-backend/controllers"
+	"ecommerce-backend/jobs"
+	"ecommerce-backend/mailer"
 	"ecommerce-backend/middleware"
+	"ecommerce-backend/models"
+	"ecommerce-backend/oidc"
+	"ecommerce-backend/password"
+	"ecommerce-backend/ratelimit"
+	"ecommerce-backend/shipping"
+	"ecommerce-backend/tax"
+	"ecommerce-backend/tokens"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func SetupRoutes(router *gin.Engine) {
+// Dependencies are the long-lived services shared by route handlers.
+type Dependencies struct {
+	Jobs       *jobs.Manager
+	RateLimits ratelimit.Store
+	Mailer     mailer.Mailer
+	Passwords  *password.Manager
+	OIDC       *oidc.Registry
+	Tokens     *tokens.Keyring
+	Taxes      tax.Calculator
+	Shipping   shipping.Quoter
+}
+
+// routeMiddleware is the middleware shared by the versioned route groups.
+type routeMiddleware struct {
+	requireAuth      gin.HandlerFunc
+	requireAuthOrKey gin.HandlerFunc
+	requireAdmin     gin.HandlerFunc
+	csrf             gin.HandlerFunc
+	canWriteProducts gin.HandlerFunc
+	canReadOrders    gin.HandlerFunc
+	authLimit        gin.HandlerFunc
+	writeLimit       gin.HandlerFunc
+}
+
+// publicPaths are served with the public CORS policy for GET and HEAD
+// requests; everything else gets the private policy.
+var publicPaths = []string{
+	"/healthz", "/readyz", "/.well-known",
+	"/api/openapi.json", "/api/docs",
+	"/api/v1/products", "/api/v2/products", "/api/products",
+	"/api/v2/exchange-rates", "/api/exchange-rates",
+}
+
+// storefrontPaths are the HTML pages and static files of the browser
+// storefront. They are not part of the API documentation.
+var storefrontPaths = []string{
+	"/", "/products", "/products/:id", "/login", "/logout",
+	"/verify-email", "/reset-password",
+	"/public/*filepath", "/assets/*filepath",
+}
+
+func SetupRoutes(router *gin.Engine, cfg *config.Config, deps Dependencies) {
+	// Registered before the routes so they also apply to preflight and
+	// unmatched requests
+	router.Use(middleware.SecurityHeaders(cfg.Security, cfg.Server.IsHTTPS()), middleware.CORS(cfg.CORS, publicPaths))
+
+	mw := routeMiddleware{
+		requireAuth:      middleware.AuthMiddleware(deps.Tokens, cfg.Session),
+		requireAuthOrKey: middleware.APIKeyOrAuthMiddleware(deps.Tokens, cfg.Session),
+		requireAdmin:     middleware.RequireRole(cfg.TwoFactor, models.RoleAdmin),
+		csrf:             middleware.CSRF(cfg.Security),
+		canWriteProducts: middleware.RequireScopeOrRole(cfg.TwoFactor, models.ScopeProductsWrite, models.RoleStaff, models.RoleAdmin),
+		canReadOrders:    middleware.RequireScopeOrRole(cfg.TwoFactor, models.ScopeOrdersRead, models.RoleStaff, models.RoleAdmin),
+		authLimit:        noop,
+		writeLimit:       noop,
+	}
+	if cfg.RateLimit.Enabled {
+		mw.authLimit = middleware.RateLimitMiddleware(deps.RateLimits,
+			middleware.RateLimitRule{Scope: "auth_ip", Limit: ratelimit.FromConfig(cfg.RateLimit.AuthIP), Key: middleware.KeyByIP},
+			middleware.RateLimitRule{Scope: "auth_account", Limit: ratelimit.FromConfig(cfg.RateLimit.AuthAccount), Key: middleware.KeyByJSONEmail},
+		)
+		mw.writeLimit = middleware.RateLimitMiddleware(deps.RateLimits,
+			middleware.RateLimitRule{Scope: "writes", Limit: ratelimit.FromConfig(cfg.RateLimit.Writes), Key: middleware.KeyByUser},
+		)
+	}
+
+	// Health checks
+	router.GET("/healthz", controllers.Healthz)
+	router.GET("/readyz", controllers.Readyz(deps.Jobs))
+
+	// Public keys for verifying our access tokens
+	router.GET("/.well-known/jwks.json", controllers.JWKS(deps.Tokens))
+
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// API documentation
+	router.GET("/api/openapi.json", controllers.OpenAPISpec(router, Docs(), apiInfo))
+	router.GET("/api/docs", controllers.SwaggerUI)
+
 	api := router.Group("/api")
-	{
-		// Health check
-		api.GET("/health", func(c *gin.Context) {
-			c.JSON(200, gin.H{"status": "OK", "message": "Server is running"})
-		})
 
-		// Auth routes
-		auth := api.Group("/auth")
+	// v1: legacy storefront-compatible, read-only catalog
+	v1 := api.Group("/v1")
+	{
+		products := v1.Group("/products")
+		products.Use(middleware.TimeoutMiddleware(cfg.Timeouts.Products))
 		{
-			auth.POST("/register", controllers.Register)
-			auth.POST("/login", controllers.Login)
+			products.GET("", controllers.GetProductsV1)
+			products.GET("/:id", controllers.GetProductV1)
 		}
+	}
 
-		// Product routes
-		products := api.Group("/products")
-		{
-			products.GET("", controllers.GetProducts)
-			products.GET("/:id", controllers.GetProduct)
+	// v2: current API. The unversioned /api routes are kept as an alias of
+	// v2 for existing clients.
+	setupV2Routes(api.Group("/v2"), cfg, deps, mw)
+	setupV2Routes(api, cfg, deps, mw)
 
-			// Protected routes
-			products.POST("", middleware.AuthMiddleware(), controllers.CreateProduct)
-			products.PUT("/:id", middleware.AuthMiddleware(), controllers.UpdateProduct)
-			products.DELETE("/:id", middleware.AuthMiddleware(), controllers.DeleteProduct)
-		}
+	setupStorefrontRoutes(router)
+}
 
-		// Cart routes (all protected)
-		cart := api.Group("/cart")
-		cart.Use(middleware.AuthMiddleware())
-		{
-			cart.POST("", controllers.AddToCart)
-			cart.GET("", controllers.GetCart)
-			cart.DELETE("/:id", controllers.RemoveFromCart)
-		}
+// setupStorefrontRoutes serves the browser storefront from views/, public/
+// and assets/, relative to the working directory.
+func setupStorefrontRoutes(router *gin.Engine) {
+	router.Static("/public", "./public")
+	router.Static("/assets", "./assets")
+	router.StaticFile("/", "./views/home.html")
+	router.StaticFile("/products", "./views/products.html")
+	router.GET("/products/:id", func(c *gin.Context) {
+		c.File("./views/product_details.html")
+	})
+	router.StaticFile("/login", "./views/login.html")
+	router.StaticFile("/logout", "./views/logout.html")
+	// Targets of the links in verification and password reset emails
+	router.StaticFile("/verify-email", "./views/verify_email.html")
+	router.StaticFile("/reset-password", "./views/reset_password.html")
+}
+
+func setupV2Routes(api *gin.RouterGroup, cfg *config.Config, deps Dependencies, mw routeMiddleware) {
+	// Auth routes
+	auth := api.Group("/auth")
+	auth.Use(mw.authLimit, middleware.TimeoutMiddleware(cfg.Timeouts.Auth))
+	{
+		auth.POST("/register", controllers.Register(cfg, deps.Mailer, deps.Passwords, deps.Tokens))
+		auth.POST("/login", controllers.Login(cfg, deps.Passwords, deps.Tokens))
+		auth.POST("/2fa/verify", controllers.VerifyLoginChallenge(cfg, deps.Tokens))
+		auth.POST("/verify-email", controllers.VerifyEmail)
+		auth.POST("/forgot-password", controllers.ForgotPassword(cfg, deps.Mailer))
+		auth.POST("/reset-password", controllers.ResetPassword(deps.Passwords))
+		auth.POST("/logout", controllers.Logout(cfg))
+		auth.GET("/csrf", controllers.CSRFToken(cfg))
+		auth.GET("/oidc", controllers.ListOIDCProviders(deps.OIDC))
+		auth.GET("/oidc/:provider/login", controllers.StartOIDCLogin(cfg, deps.OIDC))
+		auth.GET("/oidc/:provider/callback", controllers.OIDCCallback(cfg, deps.OIDC, deps.Tokens))
+	}
+
+	// Product routes
+	products := api.Group("/products")
+	products.Use(middleware.TimeoutMiddleware(cfg.Timeouts.Products))
+	{
+		products.GET("", controllers.GetProducts(cfg))
+		products.GET("/:id", controllers.GetProduct(cfg))
+
+		// Staff or API keys with the products:write scope
+		products.POST("", mw.requireAuthOrKey, mw.csrf, mw.canWriteProducts, mw.writeLimit, controllers.CreateProduct(cfg))
+		products.PUT("/:id", mw.requireAuthOrKey, mw.csrf, mw.canWriteProducts, mw.writeLimit, controllers.UpdateProduct(cfg))
+		products.DELETE("/:id", mw.requireAuthOrKey, mw.csrf, mw.canWriteProducts, mw.writeLimit, controllers.DeleteProduct)
+	}
+
+	// Exchange rates for showing estimated prices
+	api.GET("/exchange-rates", middleware.TimeoutMiddleware(cfg.Timeouts.Products), controllers.GetExchangeRates(cfg))
+
+	// Account routes (all protected)
+	me := api.Group("/me")
+	me.Use(mw.requireAuth, mw.csrf, middleware.TimeoutMiddleware(cfg.Timeouts.Auth))
+	{
+		me.GET("", controllers.GetMe)
+		me.PATCH("", mw.writeLimit, controllers.UpdateMe(cfg, deps.Mailer, deps.Passwords))
+		me.DELETE("", mw.writeLimit, controllers.DeleteMe(cfg, deps.Passwords))
+		me.POST("/password", mw.writeLimit, controllers.ChangePassword(cfg, deps.Passwords, deps.Tokens))
+		me.GET("/security-events", controllers.GetSecurityEvents)
+		me.POST("/2fa/setup", mw.writeLimit, controllers.SetupTwoFactor(cfg))
+		me.POST("/2fa/confirm", mw.writeLimit, controllers.ConfirmTwoFactor)
+		me.POST("/2fa/disable", mw.writeLimit, controllers.DisableTwoFactor(cfg, deps.Passwords))
 	}
+
+	// Cart routes (all protected)
+	cart := api.Group("/cart")
+	cart.Use(mw.requireAuth, mw.csrf, middleware.TimeoutMiddleware(cfg.Timeouts.Cart))
+	{
+		cart.POST("", mw.writeLimit, controllers.AddToCart)
+		cart.GET("", controllers.GetCart(cfg, deps.Taxes))
+		cart.DELETE("/:id", mw.writeLimit, controllers.RemoveFromCart)
+		cart.GET("/shipping-options", controllers.GetShippingOptions(cfg, deps.Taxes, deps.Shipping))
+		cart.POST("/coupon", mw.writeLimit, controllers.ApplyCoupon(cfg, deps.Taxes))
+		cart.DELETE("/coupon", mw.writeLimit, controllers.RemoveCoupon(cfg, deps.Taxes))
+	}
+
+	// Order routes (all protected)
+	orders := api.Group("/orders")
+	orders.Use(mw.requireAuth, mw.csrf, middleware.TimeoutMiddleware(cfg.Timeouts.Cart))
+	{
+		orders.POST("", mw.writeLimit, controllers.Checkout(cfg, deps.Taxes, deps.Shipping))
+		orders.GET("", controllers.ListOrders)
+		orders.GET("/:id", controllers.GetOrder)
+	}
+
+	// Every customer's orders, for staff or API keys with the orders:read
+	// scope
+	api.GET("/admin/orders", mw.requireAuthOrKey, mw.csrf, mw.canReadOrders,
+		middleware.TimeoutMiddleware(cfg.Timeouts.Cart), controllers.ListAllOrders)
+
+	// Admin routes
+	admin := api.Group("/admin")
+	admin.Use(mw.requireAuth, mw.csrf, mw.requireAdmin, middleware.TimeoutMiddleware(cfg.Timeouts.Auth))
+	{
+		admin.GET("/api-keys", controllers.ListAPIKeys)
+		admin.POST("/api-keys", mw.writeLimit, controllers.CreateAPIKey)
+		admin.DELETE("/api-keys/:id", mw.writeLimit, controllers.RevokeAPIKey)
+		admin.GET("/promotions", controllers.ListPromotions)
+		admin.POST("/promotions", mw.writeLimit, controllers.CreatePromotion)
+		admin.PUT("/promotions/:id", mw.writeLimit, controllers.UpdatePromotion)
+		admin.DELETE("/promotions/:id", mw.writeLimit, controllers.DeletePromotion)
+		admin.PUT("/exchange-rates/:currency", mw.writeLimit, controllers.SetExchangeRate(cfg))
+		admin.DELETE("/exchange-rates/:currency", mw.writeLimit, controllers.DeleteExchangeRate)
+	}
+}
+
+func noop(c *gin.Context) {
+	c.Next()
 }
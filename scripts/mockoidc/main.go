@@ -0,0 +1,36 @@
+// Command mockoidc runs a local OpenID Connect provider for trying the
+// social login flow without a real identity provider:
+//
+//	go run ./scripts/mockoidc -addr :9000 -email jane@example.com
+//
+// and configure a provider with issuer http://localhost:9000 and client id
+// gommerce. Every authorization is approved for the given user.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"ecommerce-backend/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL, matching addr")
+	clientID := flag.String("client-id", "gommerce", "accepted client id")
+	subject := flag.String("subject", "mock-user", "subject of the signed-in user")
+	email := flag.String("email", "mock.user@example.com", "email of the signed-in user")
+	verified := flag.Bool("email-verified", true, "whether the email is verified")
+	name := flag.String("name", "Mock User", "name of the signed-in user")
+	flag.Parse()
+
+	provider, err := oidctest.New(*issuer, *clientID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	provider.SetUser(oidctest.User{Subject: *subject, Email: *email, EmailVerified: *verified, Name: *name})
+
+	log.Printf("Mock OIDC provider %s signing in %s", *issuer, *email)
+	log.Fatal(http.ListenAndServe(*addr, provider.Handler()))
+}
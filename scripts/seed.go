@@ -3,30 +3,35 @@ package main
 import (
 	"context"
 	"log"
+	"os"
 	"time"
 
 	"ecommerce-backend/config"
 	"ecommerce-backend/models"
+	"ecommerce-backend/money"
 
-	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+	// Load configuration
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("Failed to load configuration: ", err)
 	}
 
 	// Connect to database
-	config.ConnectDB()
+	if err := config.ConnectDB(cfg.Database); err != nil {
+		log.Fatal("Failed to connect to MongoDB: ", err)
+	}
+	defer config.DisconnectDB(context.Background())
 
 	// Sample products
 	products := []models.Product{
 		{
 			ID:          primitive.NewObjectID(),
 			Name:        "iPhone 15 Pro",
-			Price:       999.99,
+			Price:       money.New(99999, "USD"),
 			Image:       "https://example.com/iphone15.jpg",
 			Description: "Latest iPhone with advanced camera system",
 			Category:    "Electronics",
@@ -37,7 +42,7 @@ func main() {
 		{
 			ID:          primitive.NewObjectID(),
 			Name:        "Samsung Galaxy S24",
-			Price:       799.99,
+			Price:       money.New(79999, "USD"),
 			Image:       "https://example.com/galaxy-s24.jpg",
 			Description: "Flagship Android phone with AI features",
 			Category:    "Electronics",
@@ -48,7 +53,7 @@ func main() {
 		{
 			ID:          primitive.NewObjectID(),
 			Name:        "MacBook Air M3",
-			Price:       1199.99,
+			Price:       money.New(119999, "USD"),
 			Image:       "https://example.com/macbook-air.jpg",
 			Description: "Lightweight laptop with M3 chip",
 			Category:    "Computers",
@@ -59,7 +64,7 @@ func main() {
 		{
 			ID:          primitive.NewObjectID(),
 			Name:        "Nike Air Jordan 1",
-			Price:       140.00,
+			Price:       money.New(14000, "USD"),
 			Image:       "https://example.com/jordan-1.jpg",
 			Description: "Classic basketball sneakers",
 			Category:    "Footwear",
@@ -70,7 +75,7 @@ func main() {
 		{
 			ID:          primitive.NewObjectID(),
 			Name:        "Sony WH-1000XM5",
-			Price:       349.99,
+			Price:       money.New(34999, "USD"),
 			Image:       "https://example.com/sony-headphones.jpg",
 			Description: "Premium noise-canceling headphones",
 			Category:    "Electronics",
@@ -81,7 +86,7 @@ func main() {
 		{
 			ID:          primitive.NewObjectID(),
 			Name:        "Levi's 501 Jeans",
-			Price:       89.99,
+			Price:       money.New(8999, "USD"),
 			Image:       "https://example.com/levis-jeans.jpg",
 			Description: "Classic straight-fit denim jeans",
 			Category:    "Clothing",
@@ -92,7 +97,7 @@ func main() {
 		{
 			ID:          primitive.NewObjectID(),
 			Name:        "iPad Pro 12.9",
-			Price:       1099.99,
+			Price:       money.New(109999, "USD"),
 			Image:       "https://example.com/ipad-pro.jpg",
 			Description: "Professional tablet with M2 chip",
 			Category:    "Electronics",
@@ -103,7 +108,7 @@ func main() {
 		{
 			ID:          primitive.NewObjectID(),
 			Name:        "Adidas Ultraboost 22",
-			Price:       180.00,
+			Price:       money.New(18000, "USD"),
 			Image:       "https://example.com/ultraboost.jpg",
 			Description: "High-performance running shoes",
 			Category:    "Footwear",
@@ -114,7 +119,7 @@ func main() {
 		{
 			ID:          primitive.NewObjectID(),
 			Name:        "Canon EOS R6",
-			Price:       2499.99,
+			Price:       money.New(249999, "USD"),
 			Image:       "https://example.com/canon-r6.jpg",
 			Description: "Professional mirrorless camera",
 			Category:    "Electronics",
@@ -125,7 +130,7 @@ func main() {
 		{
 			ID:          primitive.NewObjectID(),
 			Name:        "North Face Jacket",
-			Price:       299.99,
+			Price:       money.New(29999, "USD"),
 			Image:       "https://example.com/north-face-jacket.jpg",
 			Description: "Waterproof outdoor jacket",
 			Category:    "Clothing",
@@ -141,7 +146,13 @@ func main() {
 
 	// Insert products
 	for _, product := range products {
-		_, err := collection.InsertOne(ctx, product)
+		legacyID, err := config.NextSequence(ctx, "products")
+		if err != nil {
+			log.Fatal("Failed to allocate product id: ", err)
+		}
+		product.LegacyID = legacyID
+
+		_, err = collection.InsertOne(ctx, product)
 		if err != nil {
 			log.Printf("Failed to insert product %s: %v", product.Name, err)
 		} else {
@@ -0,0 +1,130 @@
+// Package shipping quotes the methods a parcel can be shipped by and what
+// they cost.
+package shipping
+
+import (
+	"math"
+	"slices"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/money"
+)
+
+// Item is one line of a parcel.
+type Item struct {
+	Weight   int64   // grams per unit
+	Volume   float64 // cm³ per unit; 0 when unknown
+	Quantity int
+}
+
+// Parcel is what is being shipped.
+type Parcel struct {
+	Items []Item
+	// Subtotal is what the items cost after discounts. Options are quoted
+	// in its currency.
+	Subtotal money.Money
+	// FreeShipping is set when a promotion waives the shipping cost.
+	FreeShipping bool
+}
+
+// Option is a shipping method available for a parcel.
+type Option struct {
+	Code string
+	Name string
+	Cost money.Money
+	// FreeOver is the subtotal from which the method is free, or nil when
+	// it always costs.
+	FreeOver *money.Money
+}
+
+// Quoter quotes the shipping methods available to a country.
+type Quoter interface {
+	Quote(country string, parcel Parcel) []Option
+}
+
+// Table is a Quoter that looks methods up in configured zones.
+type Table struct {
+	base      string
+	divisor   int64
+	zones     map[string]config.ShippingZoneConfig
+	elsewhere *config.ShippingZoneConfig
+}
+
+// NewTable returns a quoter for the zones in cfg, whose top-level amounts
+// are in the base currency.
+func NewTable(cfg config.ShippingConfig, base string) *Table {
+	t := &Table{base: base, divisor: cfg.VolumetricDivisor, zones: map[string]config.ShippingZoneConfig{}}
+	for i, zone := range cfg.Zones {
+		if len(zone.Countries) == 0 {
+			t.elsewhere = &cfg.Zones[i]
+		}
+		for _, country := range zone.Countries {
+			t.zones[country] = zone
+		}
+	}
+	return t
+}
+
+// Quote lists the methods of the country's zone that can carry the
+// parcel, cheapest first, in the currency of the parcel's subtotal.
+// Methods with no amounts in that currency are left out.
+func (t *Table) Quote(country string, parcel Parcel) []Option {
+	zone, ok := t.zones[country]
+	if !ok {
+		if t.elsewhere == nil {
+			return nil
+		}
+		zone = *t.elsewhere
+	}
+
+	weight := t.billableWeight(parcel.Items)
+	currency := parcel.Subtotal.Currency
+	var options []Option
+	for _, method := range zone.Methods {
+		if method.MaxWeight > 0 && weight > method.MaxWeight {
+			continue
+		}
+
+		amounts, ok := method.Currencies[currency]
+		if currency == t.base {
+			amounts, ok = method.ShippingAmountsConfig, true
+		}
+		if !ok {
+			continue
+		}
+
+		cost := amounts.Price
+		if method.Type == "weight" {
+			cost += amounts.PerKg * ((weight + 999) / 1000)
+		}
+
+		option := Option{Code: method.Code, Name: method.Name, Cost: money.New(cost, currency)}
+		if amounts.FreeOver > 0 {
+			freeOver := money.New(amounts.FreeOver, currency)
+			option.FreeOver = &freeOver
+			if parcel.Subtotal.Cmp(freeOver) >= 0 {
+				option.Cost = money.Zero(currency)
+			}
+		}
+		if parcel.FreeShipping {
+			option.Cost = money.Zero(currency)
+		}
+		options = append(options, option)
+	}
+	slices.SortStableFunc(options, func(a, b Option) int { return a.Cost.Cmp(b.Cost) })
+	return options
+}
+
+// billableWeight adds up the items in grams, counting each unit at the
+// larger of its actual and dimensional weight.
+func (t *Table) billableWeight(items []Item) int64 {
+	var total int64
+	for _, item := range items {
+		weight := item.Weight
+		if t.divisor > 0 && item.Volume > 0 {
+			weight = max(weight, int64(math.Ceil(item.Volume*1000/float64(t.divisor))))
+		}
+		total += weight * int64(item.Quantity)
+	}
+	return total
+}
@@ -0,0 +1,139 @@
+// Package tax works out the sales tax charged on order lines.
+package tax
+
+import (
+	"math"
+	"strings"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/money"
+)
+
+// Address is where an order is shipped, which decides the rates charged.
+type Address struct {
+	Country string // ISO 3166-1 alpha-2
+	Region  string
+}
+
+// Line is an amount to tax: the price of an order line after discounts.
+type Line struct {
+	Class  string // empty for the default class
+	Amount money.Money
+}
+
+// LineTax is the tax on one line. Net + Tax = Gross.
+type LineTax struct {
+	Net   money.Money
+	Tax   money.Money
+	Gross money.Money
+}
+
+// Amount is the tax charged under one rate across all lines.
+type Amount struct {
+	Name    string
+	Percent float64
+	Amount  money.Money
+}
+
+// Result is the tax on a set of lines.
+type Result struct {
+	Lines []LineTax // indexed like the lines passed to Calculate
+	Taxes []Amount  // one per rate charged
+	// Included reports whether the line amounts already contained the tax.
+	Included bool
+}
+
+// Calculator works out the tax on lines shipped to an address. All lines
+// are in the same currency.
+type Calculator interface {
+	Calculate(address Address, lines []Line) Result
+}
+
+// Table is a Calculator that looks rates up in a configured table.
+type Table struct {
+	inclusive    bool
+	defaultClass string
+	rates        []rate
+}
+
+type rate struct {
+	config.TaxRateConfig
+	basisPoints int64 // hundredths of a percent
+}
+
+// NewTable returns a calculator for the rates in cfg.
+func NewTable(cfg config.TaxConfig) *Table {
+	t := &Table{
+		inclusive:    cfg.Mode == "inclusive",
+		defaultClass: cfg.DefaultClass,
+	}
+	for _, r := range cfg.Rates {
+		t.rates = append(t.rates, rate{TaxRateConfig: r, basisPoints: int64(math.Round(r.Percent * 100))})
+	}
+	return t
+}
+
+// Calculate taxes each line separately, rounding each line's tax to the
+// currency's minor unit, and adds the lines up per rate. In inclusive mode
+// the tax is taken out of the line amount; otherwise it is added to it.
+func (t *Table) Calculate(address Address, lines []Line) Result {
+	result := Result{Lines: make([]LineTax, len(lines)), Included: t.inclusive}
+	totals := make([]*money.Money, len(t.rates))
+
+	for i, line := range lines {
+		class := line.Class
+		if class == "" {
+			class = t.defaultClass
+		}
+
+		var applicable []int
+		var basisPoints int64
+		for r := range t.rates {
+			if t.applies(t.rates[r], address, class) {
+				applicable = append(applicable, r)
+				basisPoints += t.rates[r].basisPoints
+			}
+		}
+
+		net := line.Amount
+		if t.inclusive {
+			net = line.Amount.MulRatio(10000, 10000+basisPoints)
+		}
+		lineTax := money.Zero(line.Amount.Currency)
+		shares := make([]money.Money, len(applicable))
+		for n, r := range applicable {
+			shares[n] = net.MulRatio(t.rates[r].basisPoints, 10000)
+			lineTax = lineTax.Add(shares[n])
+		}
+		// Inclusive prices fix the line's tax; the last rate absorbs the
+		// rounding so the parts add up to it.
+		if t.inclusive && len(applicable) > 0 {
+			included := line.Amount.Sub(net)
+			last := len(shares) - 1
+			shares[last] = shares[last].Add(included.Sub(lineTax))
+			lineTax = included
+		}
+
+		for n, r := range applicable {
+			if totals[r] == nil {
+				zero := money.Zero(line.Amount.Currency)
+				totals[r] = &zero
+			}
+			*totals[r] = totals[r].Add(shares[n])
+		}
+		result.Lines[i] = LineTax{Net: net, Tax: lineTax, Gross: net.Add(lineTax)}
+	}
+
+	for r, total := range totals {
+		if total != nil {
+			result.Taxes = append(result.Taxes, Amount{Name: t.rates[r].Name, Percent: t.rates[r].Percent, Amount: *total})
+		}
+	}
+	return result
+}
+
+func (t *Table) applies(r rate, address Address, class string) bool {
+	return r.Class == class &&
+		strings.EqualFold(r.Country, address.Country) &&
+		(r.Region == "" || strings.EqualFold(r.Region, address.Region))
+}
@@ -0,0 +1,150 @@
+package tax
+
+import (
+	"testing"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/money"
+)
+
+var rates = []config.TaxRateConfig{
+	{Name: "VAT", Country: "DE", Class: "standard", Percent: 19},
+	{Name: "VAT", Country: "DE", Class: "reduced", Percent: 7},
+	{Name: "Sales tax", Country: "US", Region: "CA", Class: "standard", Percent: 7.25},
+	{Name: "GST", Country: "CA", Class: "standard", Percent: 5},
+	{Name: "PST", Country: "CA", Region: "BC", Class: "standard", Percent: 7},
+}
+
+func TestCalculate(t *testing.T) {
+	type line struct {
+		class  string
+		amount int64
+	}
+	type lineTax struct{ net, tax, gross int64 }
+
+	tests := []struct {
+		name    string
+		mode    string
+		address Address
+		lines   []line
+		want    []lineTax
+		taxes   map[string]int64 // by rate name
+	}{
+		{
+			name:    "exclusive",
+			address: Address{Country: "DE"},
+			lines:   []line{{"", 1000}, {"reduced", 1000}},
+			want:    []lineTax{{1000, 190, 1190}, {1000, 70, 1070}},
+			taxes:   map[string]int64{"VAT": 260},
+		},
+		{
+			name:    "each line rounded on its own",
+			address: Address{Country: "US", Region: "CA"},
+			lines:   []line{{"", 10}, {"", 10}, {"", 10}}, // 0.725 each
+			want:    []lineTax{{10, 1, 11}, {10, 1, 11}, {10, 1, 11}},
+			taxes:   map[string]int64{"Sales tax": 3},
+		},
+		{
+			name:    "rates add up",
+			address: Address{Country: "CA", Region: "BC"},
+			lines:   []line{{"", 1999}},
+			want:    []lineTax{{1999, 240, 2239}},
+			taxes:   map[string]int64{"GST": 100, "PST": 140},
+		},
+		{
+			name:    "region rate only in its region",
+			address: Address{Country: "ca", Region: "on"},
+			lines:   []line{{"", 1999}},
+			want:    []lineTax{{1999, 100, 2099}},
+			taxes:   map[string]int64{"GST": 100},
+		},
+		{
+			name:    "no rate for the address",
+			address: Address{Country: "FR"},
+			lines:   []line{{"", 1000}},
+			want:    []lineTax{{1000, 0, 1000}},
+			taxes:   map[string]int64{},
+		},
+		{
+			name:    "inclusive",
+			mode:    "inclusive",
+			address: Address{Country: "DE"},
+			lines:   []line{{"", 1000}, {"reduced", 1000}},
+			want:    []lineTax{{840, 160, 1000}, {935, 65, 1000}},
+			taxes:   map[string]int64{"VAT": 225},
+		},
+		{
+			// 1000 holds 107 of tax on a net of 893; at 5% and 7% that
+			// rounds to 45 + 63, so the last rate gives up the extra cent.
+			name:    "inclusive remainder to the last rate",
+			mode:    "inclusive",
+			address: Address{Country: "CA", Region: "BC"},
+			lines:   []line{{"", 1000}},
+			want:    []lineTax{{893, 107, 1000}},
+			taxes:   map[string]int64{"GST": 45, "PST": 62},
+		},
+		{
+			name:    "inclusive without a rate",
+			mode:    "inclusive",
+			address: Address{Country: "FR"},
+			lines:   []line{{"", 999}},
+			want:    []lineTax{{999, 0, 999}},
+			taxes:   map[string]int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := NewTable(config.TaxConfig{Mode: tt.mode, DefaultClass: "standard", Rates: rates})
+
+			lines := make([]Line, len(tt.lines))
+			for i, l := range tt.lines {
+				lines[i] = Line{Class: l.class, Amount: money.New(l.amount, "EUR")}
+			}
+			result := table.Calculate(tt.address, lines)
+
+			if result.Included != (tt.mode == "inclusive") {
+				t.Errorf("Included = %v in %q mode", result.Included, tt.mode)
+			}
+			for i, want := range tt.want {
+				got := result.Lines[i]
+				if got.Net.Amount != want.net || got.Tax.Amount != want.tax || got.Gross.Amount != want.gross {
+					t.Errorf("line %d: net %d tax %d gross %d, want %d %d %d",
+						i, got.Net.Amount, got.Tax.Amount, got.Gross.Amount, want.net, want.tax, want.gross)
+				}
+			}
+
+			taxes := map[string]int64{}
+			for _, amount := range result.Taxes {
+				taxes[amount.Name] += amount.Amount.Amount
+			}
+			if len(taxes) != len(tt.taxes) {
+				t.Errorf("taxes = %v, want %v", taxes, tt.taxes)
+			}
+			for name, want := range tt.taxes {
+				if taxes[name] != want {
+					t.Errorf("%s = %d, want %d", name, taxes[name], want)
+				}
+			}
+		})
+	}
+}
+
+// Inclusive prices must come out to exactly the price charged, whatever the
+// rates round to.
+func TestInclusiveLinesKeepTheirPrice(t *testing.T) {
+	table := NewTable(config.TaxConfig{Mode: "inclusive", DefaultClass: "standard", Rates: rates})
+	for amount := int64(1); amount <= 10000; amount += 3 {
+		result := table.Calculate(Address{Country: "CA", Region: "BC"}, []Line{{Amount: money.New(amount, "CAD")}})
+
+		var taxes int64
+		for _, tax := range result.Taxes {
+			taxes += tax.Amount.Amount
+		}
+		line := result.Lines[0]
+		if line.Gross.Amount != amount || taxes != line.Tax.Amount {
+			t.Fatalf("%d: net %d tax %d gross %d, rates add up to %d",
+				amount, line.Net.Amount, line.Tax.Amount, line.Gross.Amount, taxes)
+		}
+	}
+}
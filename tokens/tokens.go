@@ -0,0 +1,248 @@
+// Package tokens issues and verifies the API's access tokens (JWTs).
+package tokens
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"ecommerce-backend/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithms supported for signing keys.
+const (
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+	HS256 = "HS256"
+)
+
+// leeway tolerates clock skew between us and other verifiers.
+const leeway = 30 * time.Second
+
+// Claims are the claims of an access token.
+type Claims struct {
+	UserID string `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+// Key is an asymmetric signing key loaded from a PEM file.
+type Key struct {
+	ID         string
+	Algorithm  string
+	ActiveFrom time.Time
+
+	private crypto.Signer
+}
+
+// Public returns the public half of the key.
+func (k *Key) Public() crypto.PublicKey {
+	return k.private.Public()
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	if k.Algorithm == EdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// Keyring signs tokens with the current key and verifies tokens signed by
+// any key that may still have unexpired tokens. Without keys it falls back
+// to HS256 with the shared secret.
+type Keyring struct {
+	cfg    config.JWTConfig
+	keys   []*Key // sorted by ActiveFrom
+	secret []byte
+	now    func() time.Time
+}
+
+// NewKeyring loads the keys configured in cfg.
+func NewKeyring(cfg config.JWTConfig) (*Keyring, error) {
+	kr := &Keyring{cfg: cfg, now: time.Now}
+	if len(cfg.Keys) == 0 {
+		kr.secret = []byte(cfg.Secret)
+		return kr, nil
+	}
+
+	for _, kc := range cfg.Keys {
+		key, err := loadKey(kc)
+		if err != nil {
+			return nil, err
+		}
+		kr.keys = append(kr.keys, key)
+	}
+	slices.SortFunc(kr.keys, func(a, b *Key) int { return a.ActiveFrom.Compare(b.ActiveFrom) })
+
+	if kr.signingKey() == nil {
+		return nil, errors.New("no jwt key is active yet")
+	}
+	return kr, nil
+}
+
+func loadKey(kc config.JWTKeyConfig) (*Key, error) {
+	data, err := os.ReadFile(kc.File)
+	if err != nil {
+		return nil, fmt.Errorf("reading jwt key %q: %w", kc.ID, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("jwt key %q: no PEM data", kc.ID)
+	}
+
+	var parsed any
+	if block.Type == "RSA PRIVATE KEY" {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jwt key %q: %w", kc.ID, err)
+	}
+
+	key := &Key{ID: kc.ID, ActiveFrom: kc.ActiveFrom}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("jwt key %q: RSA keys must be at least 2048 bits", kc.ID)
+		}
+		key.Algorithm, key.private = RS256, k
+	case ed25519.PrivateKey:
+		key.Algorithm, key.private = EdDSA, k
+	default:
+		return nil, fmt.Errorf("jwt key %q: unsupported key type %T", kc.ID, parsed)
+	}
+	return key, nil
+}
+
+// signingKey returns the most recently activated key.
+func (kr *Keyring) signingKey() *Key {
+	now := kr.now()
+	for i := len(kr.keys) - 1; i >= 0; i-- {
+		if !kr.keys[i].ActiveFrom.After(now) {
+			return kr.keys[i]
+		}
+	}
+	return nil
+}
+
+// VerificationKeys returns the keys that verify tokens: upcoming keys, the
+// signing key, and earlier keys whose tokens may not have expired yet.
+func (kr *Keyring) VerificationKeys() []*Key {
+	now := kr.now()
+	var out []*Key
+	for i, key := range kr.keys {
+		// A key stopped signing when its successor became active
+		if i+1 < len(kr.keys) && !kr.keys[i+1].ActiveFrom.Add(kr.cfg.Expiry+leeway).After(now) {
+			continue
+		}
+		out = append(out, key)
+	}
+	return out
+}
+
+// Issue returns a signed access token for the user.
+func (kr *Keyring) Issue(userID string) (string, error) {
+	now := kr.now()
+	claims := Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    kr.cfg.Issuer,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{kr.cfg.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(kr.cfg.Expiry)),
+		},
+	}
+
+	if len(kr.keys) == 0 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(kr.secret)
+	}
+
+	key := kr.signingKey()
+	if key == nil {
+		return "", errors.New("no active jwt key")
+	}
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
+}
+
+// Parse verifies a token's signature, algorithm, issuer, audience and
+// expiry and returns its claims. The algorithm must match the key named
+// by the token's kid; the alg header alone is never trusted.
+func (kr *Keyring) Parse(tokenString string) (*Claims, error) {
+	var claims Claims
+	var methods []string
+
+	keyFunc := func(token *jwt.Token) (any, error) {
+		if len(kr.keys) == 0 {
+			return kr.secret, nil
+		}
+		kid, _ := token.Header["kid"].(string)
+		for _, key := range kr.VerificationKeys() {
+			if key.ID == kid {
+				if token.Method.Alg() != key.Algorithm {
+					return nil, fmt.Errorf("algorithm %s does not match key %q", token.Method.Alg(), kid)
+				}
+				return key.Public(), nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	if len(kr.keys) == 0 {
+		methods = []string{HS256}
+	} else {
+		methods = []string{RS256, EdDSA}
+	}
+
+	_, err := jwt.ParseWithClaims(tokenString, &claims, keyFunc,
+		jwt.WithValidMethods(methods),
+		jwt.WithIssuer(kr.cfg.Issuer),
+		jwt.WithAudience(kr.cfg.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(leeway),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID == "" {
+		return nil, errors.New("token has no user_id")
+	}
+	return &claims, nil
+}
+
+// GenerateKey creates a new private key for alg (RS256 or EdDSA) encoded
+// as a PKCS #8 PEM block.
+func GenerateKey(alg string) ([]byte, error) {
+	var key any
+	var err error
+	switch alg {
+	case RS256:
+		key, err = rsa.GenerateKey(rand.Reader, 3072)
+	case EdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}